@@ -0,0 +1,56 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+// Re-encrypts the stored server passwords with a new key. Run it with the
+// same environment as Helen, then restart Helen with SERVER_RECORD_KEY set
+// to the new key:
+//
+//	rotatekey -new <base64 key>
+//
+// The old key is taken from SERVER_RECORD_KEY, or -old. Passwords stored
+// before a key was set are encrypted as well.
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+)
+
+func main() {
+	helpers.InitLogger()
+	config.SetupConstants()
+
+	oldKeyStr := flag.String("old", config.Constants.ServerRecordKey, "key the passwords are encrypted with now")
+	newKeyStr := flag.String("new", "", "key to encrypt the passwords with")
+	flag.Parse()
+
+	var oldKey []byte
+	if *oldKeyStr != "" {
+		var err error
+		if oldKey, err = helpers.ParseKey(*oldKeyStr); err != nil {
+			helpers.Logger.Fatal("Invalid old key: " + err.Error())
+		}
+	}
+
+	if *newKeyStr == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	newKey, err := helpers.ParseKey(*newKeyStr)
+	if err != nil {
+		helpers.Logger.Fatal("Invalid new key: " + err.Error())
+	}
+
+	database.Init()
+	count, err := models.RotateServerKey(oldKey, newKey)
+	if err != nil {
+		helpers.Logger.Fatal("Rotation failed, nothing was changed: " + err.Error())
+	}
+	helpers.Logger.Info("Re-encrypted %d rows, set SERVER_RECORD_KEY to the new key", count)
+}
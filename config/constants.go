@@ -5,7 +5,9 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/TF2Stadium/Helen/helpers"
@@ -28,6 +30,11 @@ type constants struct {
 	MockupAuth         bool
 	AllowedCorsOrigins []string
 
+	// ready up timeouts in seconds, keyed by lobby format ("sixes", ...)
+	// formats without an entry use ReadyUpTimeout
+	ReadyUpTimeout        int
+	ReadyUpFormatTimeouts map[string]int
+
 	// database
 	DbHost     string
 	DbPort     string
@@ -37,6 +44,35 @@ type constants struct {
 
 	SteamDevApiKey string
 	SteamApiMockUp bool
+
+	// chat filters, see controllerhelpers/chatFilters.go
+	ChatFilteredWords []string
+	ChatFilterLinks   bool
+
+	// chat anti-spam, see models/chat_spam.go
+	ChatMessagesPerMinute int // per player and room, 0 disables the limit
+	ChatBurst             int
+	ChatMaxRepeats        int // identical messages in a row
+	ChatSpamStrikes       int // rejected messages before the player gets a chat ban
+	ChatSpamBanSeconds    int
+
+	// overrides for the request rate limits, keyed by "<request>.socket" or
+	// "<request>.steamid", see controllerhelpers/rateLimits.go
+	RateLimits map[string]helpers.Rate
+
+	// "memory" for a single instance, or "postgres" to run several behind a
+	// load balancer
+	PubSubBackend string // how broadcasts get to every instance
+	LockBackend   string // how records are locked
+
+	// outgoing message queues, see broadcaster/queue.go
+	BroadcastQueueSize int      // per room or player
+	BroadcastOverflow  string   // "drop-oldest" or "drop-newest"
+	BroadcastCoalesce  []string // events where only the latest one gets sent
+
+	// base64 AES key the server passwords are encrypted with, they're
+	// stored as plaintext if it's empty
+	ServerRecordKey string
 }
 
 func overrideFromEnv(constant *string, name string) {
@@ -48,6 +84,67 @@ func overrideFromEnv(constant *string, name string) {
 
 }
 
+func overrideIntFromEnv(constant *int, name string) {
+	val := os.Getenv(name)
+	if val != "" {
+		num, err := strconv.Atoi(val)
+		if err != nil {
+			helpers.Logger.Warning("%s isn't a number: %s", name, val)
+			return
+		}
+		*constant = num
+		helpers.Logger.Debug("%s = %d", name, *constant)
+	}
+}
+
+// Reads rate limits as a comma separated list of <key>=<per minute>/<burst>,
+// like "lobbyCreate.socket=5/2,lobbyJoin.steamid=30/10"
+func overrideRateLimitsFromEnv(limits map[string]helpers.Rate, name string) {
+	val := os.Getenv(name)
+	if val == "" {
+		return
+	}
+
+	for _, entry := range strings.Split(val, ",") {
+		var key string
+		var rate helpers.Rate
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 {
+			key = strings.TrimSpace(parts[0])
+			_, err := fmt.Sscanf(parts[1], "%d/%d", &rate.PerMinute, &rate.Burst)
+			if err == nil {
+				limits[key] = rate
+				helpers.Logger.Debug("%s: %s = %d/%d", name, key, rate.PerMinute, rate.Burst)
+				continue
+			}
+		}
+		helpers.Logger.Warning("%s: invalid rate limit %s", name, entry)
+	}
+}
+
+// Reads ready up timeouts as a comma separated list of <format>=<seconds>,
+// like "sixes=60,highlander=90"
+func overrideFormatTimeoutsFromEnv(timeouts map[string]int, name string) {
+	val := os.Getenv(name)
+	if val == "" {
+		return
+	}
+
+	for _, entry := range strings.Split(val, ",") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) == 2 {
+			format := strings.TrimSpace(parts[0])
+			secs, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err == nil && secs > 0 {
+				timeouts[format] = secs
+				helpers.Logger.Debug("%s: %s = %d", name, format, secs)
+				continue
+			}
+		}
+		helpers.Logger.Warning("%s: invalid timeout %s", name, entry)
+	}
+}
+
 func overrideBoolFromEnv(constant *bool, name string) {
 	val := os.Getenv(name)
 	if val != "" {
@@ -89,6 +186,26 @@ func SetupConstants() {
 	overrideBoolFromEnv(&Constants.ServerMockUp, "PAULING_DISABLE")
 	overrideBoolFromEnv(&Constants.MockupAuth, "MOCKUP_AUTH")
 	overrideFromEnv(&Constants.LoginRedirectPath, "SERVER_REDIRECT_PATH")
+	overrideIntFromEnv(&Constants.ReadyUpTimeout, "READY_UP_TIMEOUT")
+	overrideFormatTimeoutsFromEnv(Constants.ReadyUpFormatTimeouts, "READY_UP_FORMAT_TIMEOUTS")
+	overrideFromEnv(&Constants.ServerRecordKey, "SERVER_RECORD_KEY")
+	overrideFromEnv(&Constants.PubSubBackend, "PUBSUB_BACKEND")
+	overrideFromEnv(&Constants.LockBackend, "LOCK_BACKEND")
+	overrideIntFromEnv(&Constants.BroadcastQueueSize, "BROADCAST_QUEUE_SIZE")
+	overrideFromEnv(&Constants.BroadcastOverflow, "BROADCAST_OVERFLOW")
+	overrideBoolFromEnv(&Constants.ChatFilterLinks, "CHAT_FILTER_LINKS")
+	overrideIntFromEnv(&Constants.ChatMessagesPerMinute, "CHAT_MESSAGES_PER_MINUTE")
+	overrideIntFromEnv(&Constants.ChatBurst, "CHAT_BURST")
+	overrideIntFromEnv(&Constants.ChatMaxRepeats, "CHAT_MAX_REPEATS")
+	overrideIntFromEnv(&Constants.ChatSpamStrikes, "CHAT_SPAM_STRIKES")
+	overrideIntFromEnv(&Constants.ChatSpamBanSeconds, "CHAT_SPAM_BAN_SECONDS")
+	overrideRateLimitsFromEnv(Constants.RateLimits, "RATE_LIMITS")
+	if words := os.Getenv("CHAT_FILTERED_WORDS"); words != "" {
+		Constants.ChatFilteredWords = strings.Split(words, ",")
+	}
+	if events := os.Getenv("BROADCAST_COALESCE"); events != "" {
+		Constants.BroadcastCoalesce = strings.Split(events, ",")
+	}
 	// conditional assignments
 
 	if Constants.SteamDevApiKey == "your steam dev api key" && !Constants.SteamApiMockUp {
@@ -96,6 +213,15 @@ func SetupConstants() {
 		Constants.SteamApiMockUp = true
 	}
 
+	if Constants.ServerRecordKey == "" && !Constants.ServerMockUp {
+		helpers.Logger.Warning("SERVER_RECORD_KEY not provided, server passwords will be stored as plaintext")
+	} else if Constants.ServerRecordKey != "" {
+		// carrying on would store new passwords as plaintext and leave the
+		// encrypted ones unreadable
+		if _, err := helpers.ParseKey(Constants.ServerRecordKey); err != nil {
+			helpers.Logger.Fatal("Invalid SERVER_RECORD_KEY: " + err.Error())
+		}
+	}
 }
 
 func setupDevelopmentConstants() {
@@ -113,6 +239,21 @@ func setupDevelopmentConstants() {
 	Constants.ServerMockUp = true
 	Constants.ChatLogsEnabled = false
 	Constants.AllowedCorsOrigins = []string{"*"}
+	Constants.ReadyUpTimeout = 30
+	Constants.ReadyUpFormatTimeouts = map[string]int{}
+	Constants.ChatFilteredWords = []string{}
+	Constants.ChatFilterLinks = false
+	Constants.ChatMessagesPerMinute = 20
+	Constants.ChatBurst = 5
+	Constants.ChatMaxRepeats = 3
+	Constants.ChatSpamStrikes = 5
+	Constants.ChatSpamBanSeconds = 60
+	Constants.RateLimits = map[string]helpers.Rate{}
+	Constants.PubSubBackend = "memory"
+	Constants.LockBackend = "memory"
+	Constants.BroadcastQueueSize = 100
+	Constants.BroadcastOverflow = "drop-oldest"
+	Constants.BroadcastCoalesce = []string{"lobbyData", "lobbyListData", "subListData"}
 
 	Constants.DbHost = "127.0.0.1"
 	Constants.DbPort = "5724"
@@ -131,6 +272,7 @@ func setupProductionConstants() {
 	Constants.CookieDomain = ".tf2stadium.com"
 	Constants.ServerMockUp = false
 	Constants.ChatLogsEnabled = true
+	Constants.ChatFilterLinks = true
 }
 
 func setupTestConstants() {
@@ -27,3 +27,11 @@ func TestEnvVariablesOverrideConfig(t *testing.T) {
 
 	assert.NotEqual(t, port, port2)
 }
+
+func TestReadyUpFormatTimeoutsFromEnv(t *testing.T) {
+	os.Setenv("READY_UP_FORMAT_TIMEOUTS", "sixes=60, highlander=90,ultiduo=abc")
+	defer os.Unsetenv("READY_UP_FORMAT_TIMEOUTS")
+	SetupConstants()
+
+	assert.Equal(t, map[string]int{"sixes": 60, "highlander": 90}, Constants.ReadyUpFormatTimeouts)
+}
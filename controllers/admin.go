@@ -0,0 +1,80 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/TF2Stadium/Helen/config"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/helpers/authority"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/bitly/go-simplejson"
+)
+
+// The player making the request, if they're allowed to do action. Writes
+// the error response if they aren't.
+func adminFromRequest(w http.ResponseWriter, r *http.Request, action authority.AuthAction) (*models.Player, bool) {
+	if !chelpers.IsLoggedInHTTP(r) {
+		sendJSONStatus(w, http.StatusUnauthorized, chelpers.BuildFailureJSON("Player isn't logged in.", -4))
+		return nil, false
+	}
+
+	session, _ := chelpers.GetSessionHTTP(r)
+	role, _ := session.Values["role"].(authority.AuthRole)
+	if !role.Can(action) {
+		sendJSONStatus(w, http.StatusForbidden, chelpers.BuildFailureJSON("You are not authorized to perform this action.", 0))
+		return nil, false
+	}
+
+	mod, tperr := models.GetPlayerBySteamId(session.Values["steam_id"].(string))
+	if tperr != nil {
+		sendJSONStatus(w, http.StatusUnauthorized, tperr.ErrorJSON())
+		return nil, false
+	}
+	return mod, true
+}
+
+// SendJSON with a status code
+func sendJSONStatus(w http.ResponseWriter, status int, j *simplejson.Json) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	bytes, _ := j.Encode()
+	w.Write(bytes)
+}
+
+// POSTs change things with the session cookie, so they have to come from
+// a page on our own domain, not a form some other site made the admin's
+// browser submit.
+func requirePost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return false
+	}
+	if !fromOwnDomain(r) {
+		sendJSONStatus(w, http.StatusForbidden, chelpers.BuildFailureJSON("Request didn't come from "+config.Constants.Domain, 0))
+		return false
+	}
+	return true
+}
+
+// Whether the Origin header (or the Referer, for browsers that don't send
+// it) is config.Constants.Domain. Requests with neither are refused.
+func fromOwnDomain(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	from, err := url.Parse(source)
+	if source == "" || err != nil {
+		return false
+	}
+	domain, err := url.Parse(config.Constants.Domain)
+	if err != nil {
+		return false
+	}
+	return from.Scheme == domain.Scheme && from.Host == domain.Host
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/bitly/go-simplejson"
+)
+
+// POST steamid, type, duration (in seconds) and reason
+func AdminBanHandler(w http.ResponseWriter, r *http.Request) {
+	if !requirePost(w, r) {
+		return
+	}
+	mod, ok := adminFromRequest(w, r, helpers.ActionBanPlayer)
+	if !ok {
+		return
+	}
+
+	seconds, err := strconv.Atoi(r.FormValue("duration"))
+	if err != nil {
+		sendJSONStatus(w, http.StatusBadRequest, chelpers.BuildFailureJSON("Invalid duration", 0))
+		return
+	}
+
+	ban, tperr := chelpers.BanPlayer(mod, r.FormValue("steamid"), r.FormValue("type"),
+		time.Duration(seconds)*time.Second, r.FormValue("reason"))
+	if tperr != nil {
+		sendJSONStatus(w, http.StatusBadRequest, tperr.ErrorJSON())
+		return
+	}
+	chelpers.SendJSON(w, chelpers.BuildSuccessJSON(models.DecoratePlayerBanJSON(ban)))
+}
+
+// POST steamid and type
+func AdminUnbanHandler(w http.ResponseWriter, r *http.Request) {
+	if !requirePost(w, r) {
+		return
+	}
+	mod, ok := adminFromRequest(w, r, helpers.ActionBanPlayer)
+	if !ok {
+		return
+	}
+
+	if tperr := chelpers.UnbanPlayer(mod, r.FormValue("steamid"), r.FormValue("type")); tperr != nil {
+		sendJSONStatus(w, http.StatusBadRequest, tperr.ErrorJSON())
+		return
+	}
+	chelpers.SendJSON(w, chelpers.BuildSuccessJSON(simplejson.New()))
+}
+
+// GET ?steamid=...&all=true, all includes expired and lifted bans
+func AdminGetBansHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := adminFromRequest(w, r, helpers.ActionBanPlayer); !ok {
+		return
+	}
+
+	bans, tperr := chelpers.GetPlayerBans(r.FormValue("steamid"), r.FormValue("all") != "true")
+	if tperr != nil {
+		sendJSONStatus(w, http.StatusBadRequest, tperr.ErrorJSON())
+		return
+	}
+	chelpers.SendJSON(w, chelpers.BuildSuccessJSON(bans))
+}
@@ -0,0 +1,49 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+)
+
+// GET ?format=csv|json&actor=&target=&action=&since=&until=, exports every
+// matching admin log entry as a file
+func AdminLogExportHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := adminFromRequest(w, r, helpers.ActionViewAdminLog); !ok {
+		return
+	}
+
+	since, _ := strconv.ParseInt(r.FormValue("since"), 10, 64)
+	until, _ := strconv.ParseInt(r.FormValue("until"), 10, 64)
+	query, tperr := chelpers.BuildAdminLogQuery(r.FormValue("actor"), r.FormValue("target"),
+		r.FormValue("action"), since, until)
+	if tperr != nil {
+		sendJSONStatus(w, http.StatusBadRequest, tperr.ErrorJSON())
+		return
+	}
+
+	entries, err := models.GetAllAdminLog(query)
+	if err != nil {
+		sendJSONStatus(w, http.StatusInternalServerError, chelpers.BuildFailureJSON(err.Error(), -1))
+		return
+	}
+
+	switch r.FormValue("format") {
+	case "csv":
+		w.Header().Add("Content-Type", "text/csv")
+		w.Header().Add("Content-Disposition", `attachment; filename="admin_log.csv"`)
+		models.WriteAdminLogCSV(w, entries)
+	case "", "json":
+		w.Header().Add("Content-Disposition", `attachment; filename="admin_log.json"`)
+		chelpers.SendJSON(w, models.DecorateAdminLogJSON(entries))
+	default:
+		sendJSONStatus(w, http.StatusBadRequest, chelpers.BuildFailureJSON(`Paramter "format" not valid`, 0))
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/bitly/go-simplejson"
+)
+
+// GET, the broadcaster's queue depth, drops and emit latency on this
+// instance. Times are in milliseconds.
+func AdminBroadcasterStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := adminFromRequest(w, r, helpers.ActionViewStats); !ok {
+		return
+	}
+
+	stats := broadcaster.GetStats()
+	j := simplejson.New()
+	j.Set("queued", stats.Queued)
+	j.Set("rooms", stats.Rooms)
+	j.Set("maxQueued", stats.MaxQueued)
+	j.Set("sent", stats.Sent)
+	j.Set("dropped", stats.Dropped)
+	j.Set("coalesced", stats.Coalesced)
+	j.Set("averageEmitTime", stats.AverageEmitTime().Seconds()*1000)
+	j.Set("maxEmitTime", stats.MaxEmitTime.Seconds()*1000)
+	chelpers.SendJSON(w, chelpers.BuildSuccessJSON(j))
+}
@@ -4,13 +4,10 @@
 
 package broadcaster
 
-import (
-	"time"
+import "github.com/TF2Stadium/Helen/helpers"
 
-	"github.com/TF2Stadium/Helen/helpers"
-)
-
-type broadcastMessage struct {
+// A message for a room, or for every socket of a player if Room is empty
+type Message struct {
 	Room    string
 	SteamId string
 	Event   string
@@ -21,61 +18,64 @@ type commonBroadcaster interface {
 	BroadcastTo(string, string, ...interface{})
 }
 
-var broadcasterTicker *time.Ticker
-var broadcastStopChannel chan bool
-var broadcastMessageChannel chan broadcastMessage
 var socketServer commonBroadcaster
+var messageDispatcher = newDispatcher(queueOptions, emit)
 
 func Init(server commonBroadcaster) {
-	broadcasterTicker = time.NewTicker(time.Millisecond * 1000)
-	broadcastStopChannel = make(chan bool)
-	broadcastMessageChannel = make(chan broadcastMessage)
 	socketServer = server
-	go broadcaster()
+	messageDispatcher = newDispatcher(queueOptions, emit)
+	backend.Subscribe(messageDispatcher.enqueue)
 }
 
 func Stop() {
-	broadcasterTicker.Stop()
-	broadcastStopChannel <- true
+	messageDispatcher.stop()
+	backend.Close()
+}
+
+// Counters for the messages sent out by this instance
+func GetStats() Stats {
+	return messageDispatcher.getStats()
 }
 
+func publish(message Message) {
+	if err := backend.Publish(message); err != nil {
+		helpers.Logger.Error("Couldn't publish %s: %s", message.Event, err.Error())
+	}
+}
+
+// Messages for the same room, or the same player, arrive in the order they
+// were sent. There's no order between different rooms and players: a
+// message to a player can arrive before one sent earlier to a room they're
+// in.
 func SendMessage(steamid string, event string, content string) {
-	broadcastMessageChannel <- broadcastMessage{
+	publish(Message{
 		Room:    "",
 		SteamId: steamid,
 		Event:   event,
 		Content: content,
-	}
+	})
 }
 
 func SendMessageToRoom(room string, event string, content string) {
-	broadcastMessageChannel <- broadcastMessage{
+	publish(Message{
 		Room:    room,
 		SteamId: "",
 		Event:   event,
 		Content: content,
-	}
+	})
 }
 
-func broadcaster() {
-	for {
-		select {
-		case message := <-broadcastMessageChannel:
-			if message.Room == "" {
-				socket, ok := GetSocket(message.SteamId)
-				if !ok {
-					helpers.Logger.Warning("Failed to get user's socket: %d", message.SteamId)
-					continue
-				}
-				socket.Emit(message.Event, message.Content)
-			} else {
-				socketServer.BroadcastTo(message.Room, message.Event, message.Content)
-				if message.Event == "chatReceive" {
-					helpers.Logger.Debug("Sent out a chat message: %s", message.Content)
-				}
-			}
-		case <-broadcastStopChannel:
-			return
+func emit(message Message) {
+	if message.Room == "" {
+		// the player might be connected to another instance
+		sockets := GetSockets(message.SteamId)
+		for _, socket := range sockets {
+			socket.Emit(message.Event, message.Content)
+		}
+	} else {
+		socketServer.BroadcastTo(message.Room, message.Event, message.Content)
+		if message.Event == "chatReceive" {
+			helpers.Logger.Debug("Sent out a chat message: %s", message.Content)
 		}
 	}
 }
@@ -0,0 +1,56 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package broadcaster
+
+import "sync"
+
+// Carries messages to every Helen instance, each one then sends them to the
+// sockets connected to it
+type Backend interface {
+	Publish(message Message) error
+	// handler gets every message published by any instance
+	Subscribe(handler func(Message)) error
+	Close() error
+}
+
+var backend Backend = NewMemoryBackend()
+
+// Has to be called before Init
+func SetBackend(b Backend) {
+	backend = b
+}
+
+// For running a single instance
+type memoryBackend struct {
+	lock     sync.RWMutex
+	handlers []func(Message)
+}
+
+func NewMemoryBackend() Backend {
+	return &memoryBackend{}
+}
+
+func (b *memoryBackend) Publish(message Message) error {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+	for _, handler := range b.handlers {
+		handler(message)
+	}
+	return nil
+}
+
+func (b *memoryBackend) Subscribe(handler func(Message)) error {
+	b.lock.Lock()
+	b.handlers = append(b.handlers, handler)
+	b.lock.Unlock()
+	return nil
+}
+
+func (b *memoryBackend) Close() error {
+	b.lock.Lock()
+	b.handlers = nil
+	b.lock.Unlock()
+	return nil
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package broadcaster
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/lib/pq"
+)
+
+const pubsubChannel = "helen_broadcast"
+
+// Postgres doesn't allow NOTIFY payloads of 8000 bytes or more. Bigger
+// messages are stored in pubsub_messages and only their ID is sent.
+const maxNotifyPayload = 7900
+
+// Sends messages between instances with Postgres' LISTEN/NOTIFY
+type postgresBackend struct {
+	db       *sql.DB
+	listener *pq.Listener
+	stop     chan bool
+}
+
+func NewPostgresBackend(url string, db *sql.DB) (Backend, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS pubsub_messages (
+		id serial PRIMARY KEY,
+		payload text NOT NULL,
+		created_at timestamp NOT NULL DEFAULT now())`)
+	if err != nil {
+		return nil, err
+	}
+
+	listener := pq.NewListener(url, 10*time.Second, time.Minute,
+		func(ev pq.ListenerEventType, err error) {
+			if err != nil {
+				helpers.Logger.Warning("Pub/sub listener: %s", err.Error())
+			}
+		})
+	if err := listener.Listen(pubsubChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return &postgresBackend{
+		db:       db,
+		listener: listener,
+		stop:     make(chan bool),
+	}, nil
+}
+
+func (b *postgresBackend) Publish(message Message) error {
+	bytes, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	payload := string(bytes)
+	if len(payload) > maxNotifyPayload {
+		var id int
+		err := b.db.QueryRow("INSERT INTO pubsub_messages (payload) VALUES ($1) RETURNING id", payload).Scan(&id)
+		if err != nil {
+			return err
+		}
+		payload = fmt.Sprintf("#%d", id)
+	}
+
+	_, err = b.db.Exec("SELECT pg_notify($1, $2)", pubsubChannel, payload)
+	return err
+}
+
+func (b *postgresBackend) Subscribe(handler func(Message)) error {
+	go b.listen(handler)
+	return nil
+}
+
+func (b *postgresBackend) listen(handler func(Message)) {
+	ticker := time.NewTicker(90 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case notification := <-b.listener.Notify:
+			// nil after reconnecting, anything sent in between is lost
+			if notification == nil {
+				continue
+			}
+			message, err := b.decode(notification.Extra)
+			if err != nil {
+				helpers.Logger.Warning("Pub/sub: couldn't decode message: %s", err.Error())
+				continue
+			}
+			handler(message)
+
+		case <-ticker.C:
+			go b.listener.Ping()
+			b.db.Exec("DELETE FROM pubsub_messages WHERE created_at < now() - interval '5 minutes'")
+
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+func (b *postgresBackend) decode(payload string) (Message, error) {
+	var message Message
+
+	if strings.HasPrefix(payload, "#") {
+		id, err := strconv.Atoi(payload[1:])
+		if err != nil {
+			return message, err
+		}
+		err = b.db.QueryRow("SELECT payload FROM pubsub_messages WHERE id = $1", id).Scan(&payload)
+		if err != nil {
+			return message, err
+		}
+	}
+
+	err := json.Unmarshal([]byte(payload), &message)
+	return message, err
+}
+
+func (b *postgresBackend) Close() error {
+	close(b.stop)
+	return b.listener.Close()
+}
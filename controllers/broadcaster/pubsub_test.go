@@ -0,0 +1,24 @@
+package broadcaster
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	b := NewMemoryBackend()
+
+	var first, second []Message
+	b.Subscribe(func(m Message) { first = append(first, m) })
+	b.Subscribe(func(m Message) { second = append(second, m) })
+
+	message := Message{Room: "1_public", Event: "chatReceive", Content: "{}"}
+	assert.Nil(t, b.Publish(message))
+	assert.Equal(t, []Message{message}, first)
+	assert.Equal(t, []Message{message}, second)
+
+	b.Close()
+	b.Publish(message)
+	assert.Equal(t, 1, len(first))
+}
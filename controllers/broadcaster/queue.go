@@ -0,0 +1,218 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package broadcaster
+
+import (
+	"sync"
+	"time"
+)
+
+// What happens to a message for a room whose queue is full
+type OverflowPolicy int
+
+const (
+	DropOldest OverflowPolicy = iota
+	DropNewest
+)
+
+var OverflowPolicies = map[string]OverflowPolicy{
+	"drop-oldest": DropOldest,
+	"drop-newest": DropNewest,
+}
+
+type QueueOptions struct {
+	Size     int // messages waiting per room or player
+	Overflow OverflowPolicy
+	// events where only the latest one matters, a new one replaces the one
+	// still waiting for the same room. They're also the only ones dropped
+	// when a queue is full, a queue holding nothing else grows past Size.
+	Coalesce []string
+}
+
+var queueOptions = QueueOptions{
+	Size:     100,
+	Overflow: DropOldest,
+	Coalesce: []string{"lobbyData", "lobbyListData", "subListData"},
+}
+
+// Has to be called before Init
+func SetQueueOptions(options QueueOptions) {
+	queueOptions = options
+}
+
+// Counters for this instance since Init
+type Stats struct {
+	Queued      int // messages waiting right now
+	Rooms       int // rooms and players with messages waiting
+	MaxQueued   int // in the longest queue right now
+	Sent        uint64
+	Dropped     uint64 // because the queue was full
+	Coalesced   uint64 // replaced by a newer message
+	EmitTime    time.Duration
+	MaxEmitTime time.Duration
+}
+
+// Average time a message took to go out
+func (s Stats) AverageEmitTime() time.Duration {
+	if s.Sent == 0 {
+		return 0
+	}
+	return s.EmitTime / time.Duration(s.Sent)
+}
+
+type roomQueue struct {
+	messages []Message
+}
+
+// Index of the first message for event, -1 if there's none
+func (q *roomQueue) find(event string) int {
+	for i, message := range q.messages {
+		if message.Event == event {
+			return i
+		}
+	}
+	return -1
+}
+
+// Index of the first message for any of events, -1 if there's none
+func (q *roomQueue) findAny(events map[string]bool) int {
+	for i, message := range q.messages {
+		if events[message.Event] {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *roomQueue) remove(i int) {
+	q.messages = append(q.messages[:i], q.messages[i+1:]...)
+}
+
+// Every room and player gets their own queue, emptied by a goroutine that
+// only runs while there's something in it. A slow emit only holds up the
+// messages behind it in the same queue, never the ones publishing.
+type dispatcher struct {
+	lock     sync.Mutex
+	options  QueueOptions
+	coalesce map[string]bool
+	queues   map[string]*roomQueue
+	stats    Stats
+	stopped  bool
+	emit     func(Message)
+}
+
+func newDispatcher(options QueueOptions, emit func(Message)) *dispatcher {
+	d := &dispatcher{
+		options:  options,
+		coalesce: make(map[string]bool),
+		queues:   make(map[string]*roomQueue),
+		emit:     emit,
+	}
+	if d.options.Size < 1 {
+		d.options.Size = 1
+	}
+	for _, event := range options.Coalesce {
+		d.coalesce[event] = true
+	}
+	return d
+}
+
+// Order is only kept between messages with the same key
+func queueKey(message Message) string {
+	if message.Room == "" {
+		return "steamid:" + message.SteamId
+	}
+	return "room:" + message.Room
+}
+
+func (d *dispatcher) enqueue(message Message) {
+	key := queueKey(message)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.stopped {
+		return
+	}
+
+	q, running := d.queues[key]
+	if !running {
+		q = &roomQueue{}
+		d.queues[key] = q
+	}
+
+	// a player's queue has the snapshots of every lobby they look at, only
+	// a room's are all for the same lobby
+	if d.coalesce[message.Event] && message.Room != "" {
+		if i := q.find(message.Event); i != -1 {
+			q.remove(i)
+			d.stats.Coalesced++
+		}
+	}
+
+	if len(q.messages) >= d.options.Size {
+		oldest := q.findAny(d.coalesce)
+		dropNew := d.coalesce[message.Event] && (d.options.Overflow == DropNewest || oldest == -1)
+		if dropNew {
+			d.stats.Dropped++
+			return
+		}
+		if oldest != -1 {
+			q.remove(oldest)
+			d.stats.Dropped++
+		}
+	}
+	q.messages = append(q.messages, message)
+
+	if !running {
+		go d.drain(key, q)
+	}
+}
+
+func (d *dispatcher) drain(key string, q *roomQueue) {
+	for {
+		d.lock.Lock()
+		if len(q.messages) == 0 || d.stopped {
+			delete(d.queues, key)
+			d.lock.Unlock()
+			return
+		}
+		message := q.messages[0]
+		q.messages = q.messages[1:]
+		d.lock.Unlock()
+
+		start := time.Now()
+		d.emit(message)
+		took := time.Since(start)
+
+		d.lock.Lock()
+		d.stats.Sent++
+		d.stats.EmitTime += took
+		if took > d.stats.MaxEmitTime {
+			d.stats.MaxEmitTime = took
+		}
+		d.lock.Unlock()
+	}
+}
+
+func (d *dispatcher) stop() {
+	d.lock.Lock()
+	d.stopped = true
+	d.lock.Unlock()
+}
+
+func (d *dispatcher) getStats() Stats {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stats := d.stats
+	stats.Rooms = len(d.queues)
+	for _, q := range d.queues {
+		stats.Queued += len(q.messages)
+		if len(q.messages) > stats.MaxQueued {
+			stats.MaxQueued = len(q.messages)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,169 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package broadcaster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// emits block until released, so messages pile up behind the first one
+type slowEmitter struct {
+	release chan bool
+	sent    chan Message
+}
+
+func newSlowEmitter() *slowEmitter {
+	return &slowEmitter{release: make(chan bool), sent: make(chan Message, 100)}
+}
+
+func (e *slowEmitter) emit(message Message) {
+	if message.Room == "slow" || message.SteamId == "slow" {
+		<-e.release
+	}
+	e.sent <- message
+}
+
+func (e *slowEmitter) next(t *testing.T) Message {
+	select {
+	case message := <-e.sent:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("message wasn't sent")
+	}
+	return Message{}
+}
+
+func waitFor(t *testing.T, done func() bool) {
+	for i := 0; i < 100; i++ {
+		if done() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("timed out")
+}
+
+func waitForQueued(t *testing.T, d *dispatcher, queued int) {
+	waitFor(t, func() bool { return d.getStats().Queued == queued })
+}
+
+func TestDispatcherSlowRoom(t *testing.T) {
+	e := newSlowEmitter()
+	d := newDispatcher(QueueOptions{Size: 10}, e.emit)
+	defer d.stop()
+
+	d.enqueue(Message{Room: "slow", Event: "chatReceive"})
+	// other rooms and players aren't held up by it
+	d.enqueue(Message{Room: "fast", Event: "chatReceive"})
+	d.enqueue(Message{SteamId: "76561198000000000", Event: "playerSettings"})
+	events := map[string]bool{e.next(t).Event: true, e.next(t).Event: true}
+	assert.Equal(t, map[string]bool{"chatReceive": true, "playerSettings": true}, events)
+
+	close(e.release)
+	assert.Equal(t, "slow", e.next(t).Room)
+}
+
+func TestDispatcherOverflow(t *testing.T) {
+	for _, policy := range []OverflowPolicy{DropOldest, DropNewest} {
+		e := newSlowEmitter()
+		options := QueueOptions{Size: 2, Overflow: policy, Coalesce: []string{"lobbyData", "lobbyListData"}}
+		d := newDispatcher(options, e.emit)
+
+		// the first one is taken out of the queue and stuck emitting
+		d.enqueue(Message{Room: "slow", Event: "chatReceive", Content: "0"})
+		waitForQueued(t, d, 0)
+		d.enqueue(Message{Room: "slow", Event: "lobbyData", Content: "1"})
+		d.enqueue(Message{Room: "slow", Event: "chatReceive", Content: "2"})
+		d.enqueue(Message{Room: "slow", Event: "lobbyListData", Content: "3"})
+
+		stats := d.getStats()
+		assert.Equal(t, 2, stats.Queued)
+		assert.Equal(t, uint64(1), stats.Dropped)
+
+		close(e.release)
+		var sent []string
+		for i := 0; i < 3; i++ {
+			sent = append(sent, e.next(t).Content)
+		}
+		if policy == DropOldest {
+			assert.Equal(t, []string{"0", "2", "3"}, sent)
+		} else {
+			assert.Equal(t, []string{"0", "1", "2"}, sent)
+		}
+		d.stop()
+	}
+}
+
+func TestDispatcherOverflowKeepsEvents(t *testing.T) {
+	e := newSlowEmitter()
+	d := newDispatcher(QueueOptions{Size: 2, Coalesce: []string{"lobbyData"}}, e.emit)
+	defer d.stop()
+
+	d.enqueue(Message{Room: "slow", Event: "chatReceive", Content: "0"})
+	waitForQueued(t, d, 0)
+	// nothing that can be dropped, so the queue grows past its size
+	for _, content := range []string{"1", "2", "3"} {
+		d.enqueue(Message{Room: "slow", Event: "lobbyStart", Content: content})
+	}
+	stats := d.getStats()
+	assert.Equal(t, 3, stats.Queued)
+	assert.Equal(t, uint64(0), stats.Dropped)
+
+	close(e.release)
+	var sent []string
+	for i := 0; i < 4; i++ {
+		sent = append(sent, e.next(t).Content)
+	}
+	assert.Equal(t, []string{"0", "1", "2", "3"}, sent)
+}
+
+func TestDispatcherCoalesce(t *testing.T) {
+	e := newSlowEmitter()
+	d := newDispatcher(QueueOptions{Size: 10, Coalesce: []string{"lobbyData"}}, e.emit)
+	defer d.stop()
+
+	d.enqueue(Message{Room: "slow", Event: "chatReceive", Content: "0"})
+	waitForQueued(t, d, 0)
+	d.enqueue(Message{Room: "slow", Event: "lobbyData", Content: "1"})
+	d.enqueue(Message{Room: "slow", Event: "chatReceive", Content: "2"})
+	d.enqueue(Message{Room: "slow", Event: "lobbyData", Content: "3"})
+	assert.Equal(t, uint64(1), d.getStats().Coalesced)
+
+	close(e.release)
+	var sent []string
+	for i := 0; i < 3; i++ {
+		sent = append(sent, e.next(t).Content)
+	}
+	assert.Equal(t, []string{"0", "2", "3"}, sent)
+
+	// empty queues go away
+	waitFor(t, func() bool { return d.getStats().Rooms == 0 })
+	stats := d.getStats()
+	assert.Equal(t, uint64(3), stats.Sent)
+	assert.True(t, stats.MaxEmitTime > 0)
+}
+
+func TestDispatcherNoCoalescePlayer(t *testing.T) {
+	e := newSlowEmitter()
+	d := newDispatcher(QueueOptions{Size: 10, Coalesce: []string{"lobbyData"}}, e.emit)
+	defer d.stop()
+
+	// a player's queue gets the snapshots of different lobbies
+	d.enqueue(Message{SteamId: "slow", Event: "chatReceive", Content: "0"})
+	waitForQueued(t, d, 0)
+	d.enqueue(Message{SteamId: "slow", Event: "lobbyData", Content: "1"})
+	d.enqueue(Message{SteamId: "slow", Event: "lobbyData", Content: "2"})
+	assert.Equal(t, uint64(0), d.getStats().Coalesced)
+
+	close(e.release)
+	var sent []string
+	for i := 0; i < 3; i++ {
+		sent = append(sent, e.next(t).Content)
+	}
+	assert.Equal(t, []string{"0", "1", "2"}, sent)
+}
@@ -5,27 +5,43 @@ import (
 	"sync"
 )
 
-var steamIdSocketMap = make(map[string]socketio.Socket)
+// every socket a player has open, by socket ID
+var steamIdSocketMap = make(map[string]map[string]socketio.Socket)
 var steamIdSocketMapLock sync.Mutex
 
 func SetSocket(steamid string, so socketio.Socket) {
 	steamIdSocketMapLock.Lock()
 	defer steamIdSocketMapLock.Unlock()
 
-	steamIdSocketMap[steamid] = so
+	sockets, ok := steamIdSocketMap[steamid]
+	if !ok {
+		sockets = make(map[string]socketio.Socket)
+		steamIdSocketMap[steamid] = sockets
+	}
+	sockets[so.Id()] = so
 }
 
-func RemoveSocket(steamid string) {
+// Removes one of the player's sockets, returns whether they still have others
+func RemoveSocket(steamid string, so socketio.Socket) bool {
 	steamIdSocketMapLock.Lock()
 	defer steamIdSocketMapLock.Unlock()
 
-	delete(steamIdSocketMap, steamid)
+	sockets := steamIdSocketMap[steamid]
+	delete(sockets, so.Id())
+	if len(sockets) == 0 {
+		delete(steamIdSocketMap, steamid)
+		return false
+	}
+	return true
 }
 
-func GetSocket(steamid string) (so socketio.Socket, success bool) {
+func GetSockets(steamid string) []socketio.Socket {
 	steamIdSocketMapLock.Lock()
 	defer steamIdSocketMapLock.Unlock()
 
-	so, success = steamIdSocketMap[steamid]
-	return
+	var sockets []socketio.Socket
+	for _, so := range steamIdSocketMap[steamid] {
+		sockets = append(sockets, so)
+	}
+	return sockets
 }
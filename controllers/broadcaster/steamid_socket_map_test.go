@@ -0,0 +1,36 @@
+package broadcaster
+
+import (
+	"testing"
+
+	"github.com/googollee/go-socket.io"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSocket struct {
+	socketio.Socket
+	id string
+}
+
+func (so fakeSocket) Id() string {
+	return so.id
+}
+
+func TestSocketMap(t *testing.T) {
+	first := fakeSocket{id: "1"}
+	second := fakeSocket{id: "2"}
+
+	SetSocket("steamid", first)
+	SetSocket("steamid", second)
+	SetSocket("steamid", second)
+	assert.Equal(t, 2, len(GetSockets("steamid")))
+
+	assert.True(t, RemoveSocket("steamid", first))
+	sockets := GetSockets("steamid")
+	assert.Equal(t, 1, len(sockets))
+	assert.Equal(t, "2", sockets[0].Id())
+
+	assert.False(t, RemoveSocket("steamid", second))
+	assert.Equal(t, 0, len(GetSockets("steamid")))
+	assert.False(t, RemoveSocket("other", first))
+}
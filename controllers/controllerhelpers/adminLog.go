@@ -0,0 +1,41 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllerhelpers
+
+import (
+	"time"
+
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+)
+
+// Builds an admin log query from request parameters. actor and target are
+// SteamIDs, since and until unix times, empty or zero ones match everything.
+func BuildAdminLogQuery(actor string, target string, action string, since int64, until int64) (models.AdminLogQuery, *helpers.TPError) {
+	query := models.AdminLogQuery{Action: action}
+
+	if actor != "" {
+		player, tperr := models.GetPlayerBySteamId(actor)
+		if tperr != nil {
+			return query, tperr
+		}
+		query.ActorID = player.ID
+	}
+	if target != "" {
+		player, tperr := models.GetPlayerBySteamId(target)
+		if tperr != nil {
+			return query, tperr
+		}
+		query.TargetID = player.ID
+	}
+
+	if since != 0 {
+		query.Since = time.Unix(since, 0)
+	}
+	if until != 0 {
+		query.Until = time.Unix(until, 0)
+	}
+	return query, nil
+}
@@ -98,6 +98,12 @@ func GetPlayerSocket(socketid string) (*models.Player, error) {
 	return models.GetPlayerBySteamId(steamid)
 }
 
+// Whether the socket's player is allowed to do action
+func CanSocket(socketid string, action authority.AuthAction) bool {
+	role, err := GetPlayerRole(socketid)
+	return err == nil && role.Can(action)
+}
+
 func GetPlayerRole(socketid string) (authority.AuthRole, error) {
 	session, err := GetSessionSocket(socketid)
 	if err != nil {
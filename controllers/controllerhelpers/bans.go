@@ -0,0 +1,94 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllerhelpers
+
+import (
+	"time"
+
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/bitly/go-simplejson"
+)
+
+// Bans steamid for duration on behalf of mod, and lets them know if
+// they're online. Used by both the socket and HTTP handlers.
+func BanPlayer(mod *models.Player, steamid string, banType string, duration time.Duration, reason string) (*models.PlayerBan, *helpers.TPError) {
+	t, ok := BanTypeMap[banType]
+	if !ok {
+		return nil, helpers.NewTPError("Invalid ban type", 0)
+	}
+	if duration <= 0 {
+		return nil, helpers.NewTPError("The ban has to last for some time.", 0)
+	}
+	if reason == "" {
+		return nil, helpers.NewTPError("A reason is needed.", 0)
+	}
+
+	target, tperr := models.GetPlayerBySteamId(steamid)
+	if tperr != nil {
+		return nil, tperr
+	}
+	if target.ID == mod.ID {
+		return nil, helpers.NewTPError("You can't ban yourself.", 0)
+	}
+
+	ban, err := target.Ban(t, time.Now().Add(duration), reason, mod)
+	if err != nil {
+		return nil, helpers.NewTPErrorFromError(err)
+	}
+	models.LogAdminChange(mod.ID, helpers.ActionBanPlayer, target.ID, nil,
+		map[string]interface{}{"type": banType, "until": ban.Until.Unix(), "reason": reason})
+
+	bytes, _ := models.DecoratePlayerBanJSON(ban).Encode()
+	broadcaster.SendMessage(target.SteamId, "playerBanned", string(bytes))
+	return ban, nil
+}
+
+// Lifts steamid's active bans of the type
+func UnbanPlayer(mod *models.Player, steamid string, banType string) *helpers.TPError {
+	t, ok := BanTypeMap[banType]
+	if !ok {
+		return helpers.NewTPError("Invalid ban type", 0)
+	}
+
+	target, tperr := models.GetPlayerBySteamId(steamid)
+	if tperr != nil {
+		return tperr
+	}
+
+	_, until := target.IsBannedWithTime(t)
+	if err := target.Unban(t); err != nil {
+		return helpers.NewTPErrorFromError(err)
+	}
+	models.LogCustomAdminChange(mod.ID, "ActionUnbanPlayer", target.ID,
+		map[string]interface{}{"type": banType, "until": until.Unix()}, nil)
+
+	j := simplejson.New()
+	j.Set("type", banType)
+	bytes, _ := j.Encode()
+	broadcaster.SendMessage(target.SteamId, "playerUnbanned", string(bytes))
+	return nil
+}
+
+// Every ban steamid has had, active ones only if activeOnly is set
+func GetPlayerBans(steamid string, activeOnly bool) (*simplejson.Json, *helpers.TPError) {
+	target, tperr := models.GetPlayerBySteamId(steamid)
+	if tperr != nil {
+		return nil, tperr
+	}
+
+	var bans []*models.PlayerBan
+	var err error
+	if activeOnly {
+		bans, err = target.GetActiveBans()
+	} else {
+		bans, err = target.GetBans()
+	}
+	if err != nil {
+		return nil, helpers.NewTPErrorFromError(err)
+	}
+	return models.DecoratePlayerBanListJSON(bans), nil
+}
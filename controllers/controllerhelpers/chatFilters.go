@@ -0,0 +1,78 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllerhelpers
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/TF2Stadium/Helen/config"
+)
+
+// A step of the chat filter pipeline. It gets the message as left by the
+// previous one and returns what's left of it.
+type ChatFilter func(message string) string
+
+// Run in order on every message before it's sent
+var ChatFilters = []ChatFilter{filterWords, filterLinks}
+
+func FilterChatMessage(message string) string {
+	for _, filter := range ChatFilters {
+		message = filter(message)
+	}
+	return message
+}
+
+// config.Constants.ChatFilteredWords compiled into one regexp, redone
+// only when the list changes
+var filteredWords struct {
+	sync.Mutex
+	list   string
+	regexp *regexp.Regexp
+}
+
+func filteredWordsRegexp() *regexp.Regexp {
+	list := strings.Join(config.Constants.ChatFilteredWords, ",")
+
+	filteredWords.Lock()
+	defer filteredWords.Unlock()
+	if list == filteredWords.list {
+		return filteredWords.regexp
+	}
+
+	var quoted []string
+	for _, word := range config.Constants.ChatFilteredWords {
+		if word = strings.TrimSpace(word); word != "" {
+			quoted = append(quoted, regexp.QuoteMeta(word))
+		}
+	}
+	filteredWords.list = list
+	filteredWords.regexp = nil
+	if len(quoted) != 0 {
+		filteredWords.regexp = regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	}
+	return filteredWords.regexp
+}
+
+// Stars out the words in config.Constants.ChatFilteredWords, ignoring case
+func filterWords(message string) string {
+	re := filteredWordsRegexp()
+	if re == nil {
+		return message
+	}
+	return re.ReplaceAllStringFunc(message, func(match string) string {
+		return strings.Repeat("*", len(match))
+	})
+}
+
+var linkRegexp = regexp.MustCompile(`(?i)\b((https?|ftp)://|www\.)\S+`)
+
+func filterLinks(message string) string {
+	if !config.Constants.ChatFilterLinks {
+		return message
+	}
+	return linkRegexp.ReplaceAllString(message, "[link removed]")
+}
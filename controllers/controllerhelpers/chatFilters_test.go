@@ -0,0 +1,34 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllerhelpers
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterChatMessage(t *testing.T) {
+	config.Constants.ChatFilteredWords = []string{"heck", "darn"}
+	config.Constants.ChatFilterLinks = true
+	defer func() {
+		config.Constants.ChatFilteredWords = nil
+		config.Constants.ChatFilterLinks = false
+	}()
+
+	assert.Equal(t, "what the ****", FilterChatMessage("what the heck"))
+	assert.Equal(t, "****it, ****", FilterChatMessage("DARNit, Heck"))
+	assert.Equal(t, "join [link removed] now", FilterChatMessage("join http://example.com/lobby?id=1 now"))
+	assert.Equal(t, "[link removed]", FilterChatMessage("www.example.com"))
+	assert.Equal(t, "gg wp", FilterChatMessage("gg wp"))
+
+	config.Constants.ChatFilterLinks = false
+	assert.Equal(t, "http://example.com", FilterChatMessage("http://example.com"))
+
+	// the list can change
+	config.Constants.ChatFilteredWords = []string{"gosh"}
+	assert.Equal(t, "oh ****, heck", FilterChatMessage("oh gosh, heck"))
+}
@@ -24,6 +24,7 @@ type FilterParams struct {
 	Action      authority.AuthAction
 	FilterLogin bool
 	Params      map[string]Param
+	RateLimit   RateLimit
 }
 
 func FilterRequest(so socketio.Socket, filters FilterParams, f func(map[string]interface{}) string) func(string) string {
@@ -45,6 +46,11 @@ func FilterRequest(so socketio.Socket, filters FilterParams, f func(map[string]i
 			}
 		}
 
+		if tperr := filters.RateLimit.check(so.Id()); tperr != nil {
+			bytes, _ := tperr.ErrorJSON().Encode()
+			return string(bytes)
+		}
+
 		if filters.Params == nil {
 			return f(nil)
 		}
@@ -0,0 +1,125 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllerhelpers
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/bitly/go-simplejson"
+)
+
+var matchmakerTicker *time.Ticker
+
+func StartMatchmaker() {
+	matchmakerTicker = time.NewTicker(time.Second * 5)
+	go matchmaker()
+}
+
+func matchmaker() {
+	for {
+		<-matchmakerTicker.C
+		for _, match := range models.FindMatches() {
+			startMatch(match)
+		}
+		BroadcastQueueStatus()
+	}
+}
+
+func startMatch(match models.Match) {
+	var entries []*models.QueueEntry
+	for _, slot := range match.Slots {
+		entries = append(entries, slot.Entry)
+	}
+
+	server, tperr := models.ReserveServer(match.Type, "")
+	if tperr != nil {
+		helpers.Logger.Warning("Couldn't start matched lobby: %s", tperr.Error())
+		models.RequeueEntries(entries)
+		return
+	}
+
+	randBytes := make([]byte, 6)
+	rand.Read(randBytes)
+	info, err := server.Record(base64.URLEncoding.EncodeToString(randBytes))
+	if err != nil {
+		helpers.Logger.Warning("Couldn't start matched lobby: %s", err.Error())
+		models.ReleaseServer(server.ID)
+		models.RequeueEntries(entries)
+		return
+	}
+
+	lob := models.NewLobby(models.RandomMatchMap(match.Type), match.Type, match.League, info, 0, false)
+	lob.Save()
+	server.AssignLobby(lob)
+	if err := lob.SetupServer(); err != nil {
+		helpers.Logger.Warning("Couldn't set up server for matched lobby #%d: %s", lob.ID, err.Error())
+		lob.Close(false, models.TriggerMatchmaker)
+		models.RequeueEntries(entries)
+		return
+	}
+
+	lob.SetState(models.LobbyStateWaiting, models.TriggerMatchmaker)
+	lob.Save()
+
+	matchFound := simplejson.New()
+	matchFound.Set("id", lob.ID)
+	bytes, _ := matchFound.Encode()
+
+	for _, slot := range match.Slots {
+		player, tperr := models.GetPlayerBySteamId(slot.Entry.SteamId)
+		if tperr != nil {
+			continue
+		}
+
+		if tperr = models.LockLobby(lob.ID); tperr == nil {
+			tperr = lob.AddPlayer(player, slot.Slot)
+			models.UnlockLobby(lob.ID)
+		}
+		if tperr != nil {
+			helpers.Logger.Warning("Couldn't add %s to matched lobby #%d: %s",
+				player.SteamId, lob.ID, tperr.Error())
+			continue
+		}
+
+		for _, so := range broadcaster.GetSockets(player.SteamId) {
+			AfterLobbyJoin(so, lob, player)
+			AfterLobbySpec(so, lob)
+		}
+		broadcaster.SendMessage(player.SteamId, "queueMatchFound", string(bytes))
+		models.BroadcastLobbyToUser(lob, player.SteamId)
+	}
+
+	// if someone couldn't be added the lobby stays open like any other
+	// lobby, so the missing slots can be filled by hand
+	if lob.IsFull() {
+		lob.SetState(models.LobbyStateReadyingUp, models.TriggerMatchmaker)
+		lob.Save()
+		lob.StartReadyUpTimer()
+		BroadcastReadyUp(lob)
+	}
+	models.BroadcastLobbyList()
+}
+
+func DecorateQueueStatusJSON(status models.QueueStatus) *simplejson.Json {
+	j := simplejson.New()
+	j.Set("type", status.Type.Format().Name)
+	j.Set("league", status.League)
+	j.Set("position", status.Position)
+	j.Set("eta", int(status.ETA.Seconds()))
+	return j
+}
+
+// Sends every queued player their position and estimated wait
+func BroadcastQueueStatus() {
+	for _, status := range models.GetQueueStatuses() {
+		bytes, _ := DecorateQueueStatusJSON(status).Encode()
+		broadcaster.SendMessage(status.SteamId, "queueStatus", string(bytes))
+	}
+}
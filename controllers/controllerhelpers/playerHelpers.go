@@ -47,21 +47,38 @@ func AfterLobbySpecLeave(so socketio.Socket, lobby *models.Lobby) {
 func AfterConnect(so socketio.Socket) {
 	so.Join(fmt.Sprintf("%s_public", config.Constants.GlobalChatRoom)) //room for global chat
 
-	var lobbies []models.Lobby
-	err := db.DB.Where("state = ?", models.LobbyStateWaiting).Order("id desc").Find(&lobbies).Error
+	EmitLobbyList(so)
+
+	if subs, err := models.GetOpenSubstitutes(); err == nil {
+		bytes, _ := models.DecorateSubListJSON(subs).Encode()
+		so.Emit("subListData", string(bytes))
+	}
+	BroadcastScrollback(so, 0)
+}
+
+// Sends the public lobby list, then the private lobbies the player can see
+// as their own event for the client to merge in. They're kept apart so a
+// public list broadcast to everyone can't replace a player's private ones.
+func EmitLobbyList(so socketio.Socket) {
+	lobbies, err := models.GetLobbyListFor("")
 	if err != nil {
 		helpers.Logger.Critical("%s", err.Error())
 		return
 	}
-
 	list, err := models.DecorateLobbyListData(lobbies)
 	if err != nil {
 		helpers.Logger.Critical("Failed to send lobby list: %s", err.Error())
 		return
 	}
-
 	so.Emit("lobbyListData", list)
-	BroadcastScrollback(so, 0)
+
+	if !IsLoggedInSocket(so.Id()) {
+		return
+	}
+	private, _ := models.GetPrivateLobbyListFor(GetSteamId(so.Id()))
+	if list, err := models.DecorateLobbyListData(private); err == nil {
+		so.Emit("privateLobbyListData", list)
+	}
 }
 
 func AfterConnectLoggedIn(so socketio.Socket, player *models.Player) {
@@ -102,6 +119,15 @@ func AfterConnectLoggedIn(so socketio.Socket, player *models.Player) {
 
 }
 
+// Tells the players in the lobby to ready up, and how long they have left
+func BroadcastReadyUp(lobby *models.Lobby) {
+	left := simplejson.New()
+	left.Set("timeout", lobby.ReadyUpTimeLeft())
+	bytes, _ := left.Encode()
+	room := fmt.Sprintf("%s_private", GetLobbyRoom(lobby.ID))
+	broadcaster.SendMessageToRoom(room, "lobbyReadyUp", string(bytes))
+}
+
 func GetLobbyRoom(lobbyid uint) string {
 	return strconv.FormatUint(uint64(lobbyid), 10)
 }
@@ -0,0 +1,55 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllerhelpers
+
+import (
+	"fmt"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/helpers"
+)
+
+// Returned by FilterRequest when a request is made too often
+const ErrorCodeRateLimited = 15
+
+// How often a request can be made, by a single socket and by a single
+// player over all their sockets. Either can be left empty. Name keeps the
+// buckets of different requests apart and is what the limits are overridden
+// with in config.Constants.RateLimits, as "<name>.socket" and "<name>.steamid".
+type RateLimit struct {
+	Name       string
+	PerSocket  helpers.Rate
+	PerSteamId helpers.Rate
+}
+
+var requestLimiter = helpers.NewRateLimiter()
+
+func (limit RateLimit) rate(scope string, def helpers.Rate) helpers.Rate {
+	if rate, ok := config.Constants.RateLimits[limit.Name+"."+scope]; ok {
+		return rate
+	}
+	return def
+}
+
+// Takes a request from the socket's and the player's buckets, the player's
+// only if the socket is logged in
+func (limit RateLimit) check(socketid string) *helpers.TPError {
+	if limit.Name == "" {
+		return nil
+	}
+
+	ok, wait := requestLimiter.Take(fmt.Sprintf("%s_socket_%s", limit.Name, socketid),
+		limit.rate("socket", limit.PerSocket))
+	if ok && IsLoggedInSocket(socketid) {
+		ok, wait = requestLimiter.Take(fmt.Sprintf("%s_steamid_%s", limit.Name, GetSteamId(socketid)),
+			limit.rate("steamid", limit.PerSteamId))
+	}
+
+	if !ok {
+		return helpers.NewRetryTPError("You're doing that too often, please wait a bit.",
+			ErrorCodeRateLimited, wait)
+	}
+	return nil
+}
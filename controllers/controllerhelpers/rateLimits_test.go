@@ -0,0 +1,38 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package controllerhelpers
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitCheck(t *testing.T) {
+	limit := RateLimit{
+		Name:      "testRequest",
+		PerSocket: helpers.Rate{PerMinute: 1, Burst: 2},
+	}
+
+	assert.Nil(t, limit.check("socket1"))
+	assert.Nil(t, limit.check("socket1"))
+	tperr := limit.check("socket1")
+	assert.NotNil(t, tperr)
+	assert.Equal(t, ErrorCodeRateLimited, tperr.Code)
+	assert.True(t, tperr.RetryAfter > 0)
+
+	assert.Nil(t, limit.check("socket2"))
+
+	config.Constants.RateLimits = map[string]helpers.Rate{
+		"testRequest.socket": helpers.Rate{},
+	}
+	defer func() { config.Constants.RateLimits = map[string]helpers.Rate{} }()
+	// overridden with no limit
+	assert.Nil(t, limit.check("socket1"))
+
+	assert.Nil(t, RateLimit{}.check("socket1"))
+}
@@ -15,8 +15,8 @@ import (
 
 func SendJSON(w http.ResponseWriter, json *simplejson.Json) {
 	w.Header().Add("Content-Type", "application/json")
-	val, _ := json.String()
-	fmt.Fprintf(w, val)
+	bytes, _ := json.Encode()
+	w.Write(bytes)
 }
 
 func BuildSuccessJSON(data *simplejson.Json) *simplejson.Json {
@@ -0,0 +1,97 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+)
+
+// An event sent by Pauling, decoded into the struct registered for its name
+type Event interface {
+	Validate() error
+	Handle()
+}
+
+var registry = make(map[string]func() Event)
+
+// Registers the struct events with the given name are decoded into. The
+// struct's fields are filled from the event using their json tags.
+func Register(name string, newEvent func() Event) {
+	registry[name] = newEvent
+}
+
+type LobbyEvent struct {
+	LobbyId uint `json:"lobbyId"`
+}
+
+func (e *LobbyEvent) Validate() error {
+	if e.LobbyId == 0 {
+		return errors.New("missing lobbyId")
+	}
+	return nil
+}
+
+type PlayerEvent struct {
+	LobbyEvent
+	SteamId string `json:"steamId"`
+}
+
+func (e *PlayerEvent) Validate() error {
+	if err := e.LobbyEvent.Validate(); err != nil {
+		return err
+	}
+	if e.SteamId == "" {
+		return errors.New("missing steamId")
+	}
+	return nil
+}
+
+func Decode(raw models.Event) (Event, error) {
+	name, ok := raw["name"].(string)
+	if !ok {
+		return nil, errors.New("event has no name")
+	}
+
+	newEvent, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %s", name)
+	}
+
+	bytes, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	event := newEvent()
+	if err := json.Unmarshal(bytes, event); err != nil {
+		return nil, fmt.Errorf("malformed %s event: %s", name, err.Error())
+	}
+	if err := event.Validate(); err != nil {
+		return nil, fmt.Errorf("malformed %s event: %s", name, err.Error())
+	}
+
+	return event, nil
+}
+
+// Decodes and handles an event. Malformed events are logged and skipped.
+func Dispatch(raw models.Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			helpers.Logger.Critical("Panic while handling event %v: %v", raw, r)
+		}
+	}()
+
+	event, err := Decode(raw)
+	if err != nil {
+		helpers.Logger.Warning("Skipping event from Pauling: %s", err.Error())
+		return
+	}
+	event.Handle()
+}
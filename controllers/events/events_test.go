@@ -0,0 +1,122 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package events
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	helpers.InitLogger()
+}
+
+func TestDecodeEvent(t *testing.T) {
+	event, err := Decode(models.Event{"name": "playerConn", "lobbyId": 3, "steamId": "76561198074578368"})
+	assert.Nil(t, err)
+	conn, ok := event.(*PlayerConn)
+	assert.True(t, ok)
+	assert.Equal(t, uint(3), conn.LobbyId)
+	assert.Equal(t, "76561198074578368", conn.SteamId)
+
+	event, err = Decode(models.Event{"name": "matchEnded", "lobbyId": 5})
+	assert.Nil(t, err)
+	ended, ok := event.(*MatchEnded)
+	assert.True(t, ok)
+	assert.Equal(t, uint(5), ended.LobbyId)
+
+	_, err = Decode(models.Event{"name": "getServers"})
+	assert.Nil(t, err)
+}
+
+func TestDecodeMalformedEvent(t *testing.T) {
+	// wrong type
+	_, err := Decode(models.Event{"name": "playerDisc", "lobbyId": "3", "steamId": "76561198074578368"})
+	assert.NotNil(t, err)
+
+	// missing fields
+	_, err = Decode(models.Event{"name": "playerDisc", "lobbyId": 3})
+	assert.NotNil(t, err)
+	_, err = Decode(models.Event{"name": "matchEnded"})
+	assert.NotNil(t, err)
+
+	// unknown or missing name
+	_, err = Decode(models.Event{"name": "foo", "lobbyId": 3})
+	assert.NotNil(t, err)
+	_, err = Decode(models.Event{"lobbyId": 3})
+	assert.NotNil(t, err)
+}
+
+func TestDispatchMalformedEvent(t *testing.T) {
+	assert.NotPanics(t, func() {
+		Dispatch(models.Event{"name": "playerRep", "steamId": 42})
+	})
+}
+
+func TestPaulingEvents(t *testing.T) {
+	testhelpers.CleanupDB()
+	pauling, err := testhelpers.StartFakePauling()
+	assert.Nil(t, err)
+	defer pauling.Close()
+	StartListener()
+
+	lobby := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "ugc", models.ServerRecord{}, 0, false)
+	lobby.Save()
+	player := testhelpers.CreatePlayer()
+	lobby.AddPlayer(player, 0)
+
+	assert.Nil(t, pauling.PlayerConn(lobby.ID, player.SteamId))
+	inGame, _ := lobby.IsPlayerInGame(player)
+	assert.True(t, inGame)
+
+	assert.Nil(t, pauling.PlayerDisc(lobby.ID, player.SteamId))
+	inGame, _ = lobby.IsPlayerInGame(player)
+	assert.False(t, inGame)
+
+	// events for players that don't exist are skipped
+	assert.Nil(t, pauling.PlayerConn(lobby.ID, "0"))
+
+	assert.Nil(t, pauling.PlayerRep(lobby.ID, player.SteamId))
+	_, err = lobby.GetPlayerSlot(player)
+	assert.NotNil(t, err)
+
+	lobby.SetState(models.LobbyStateWaiting, models.TriggerDebug)
+	lobby.Save()
+	assert.Nil(t, pauling.GetServers())
+	verifiers := pauling.CallsTo("SetupVerifier")
+	assert.Equal(t, 1, len(verifiers))
+	assert.Equal(t, lobby.ID, verifiers[0].(*models.ServerBootstrap).LobbyId)
+
+	assert.Nil(t, pauling.MatchEnded(lobby.ID))
+	lobby, _ = models.GetLobbyById(lobby.ID)
+	assert.Equal(t, models.LobbyStateEnded, lobby.State)
+}
+
+func TestPaulingEventsPolling(t *testing.T) {
+	testhelpers.CleanupDB()
+	pauling, err := testhelpers.StartFakePauling()
+	assert.Nil(t, err)
+	defer pauling.Close()
+	StartListener()
+
+	// a Pauling from before WaitEvent
+	pauling.Fail("WaitEvent", "rpc: can't find method Pauling.WaitEvent")
+
+	lobby := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "ugc", models.ServerRecord{}, 0, false)
+	lobby.Save()
+	player := testhelpers.CreatePlayer()
+	lobby.AddPlayer(player, 0)
+
+	// the first one might still go through a WaitEvent call made earlier
+	assert.Nil(t, pauling.PlayerConn(lobby.ID, player.SteamId))
+	assert.Nil(t, pauling.PlayerDisc(lobby.ID, player.SteamId))
+	inGame, _ := lobby.IsPlayerInGame(player)
+	assert.False(t, inGame)
+	assert.NotEqual(t, 0, len(pauling.CallsTo("GetEvent")))
+}
@@ -0,0 +1,185 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+)
+
+type PlayerDisc struct{ PlayerEvent }
+type PlayerConn struct{ PlayerEvent }
+type PlayerRep struct{ PlayerEvent }
+type DiscFromServer struct{ LobbyEvent }
+type MatchEnded struct{ LobbyEvent }
+type GetServers struct{}
+
+func init() {
+	Register("playerDisc", func() Event { return &PlayerDisc{} })
+	Register("playerConn", func() Event { return &PlayerConn{} })
+	Register("playerRep", func() Event { return &PlayerRep{} })
+	Register("discFromServer", func() Event { return &DiscFromServer{} })
+	Register("matchEnded", func() Event { return &MatchEnded{} })
+	Register("getServers", func() Event { return &GetServers{} })
+}
+
+func publicRoom(lobbyid uint) string {
+	return fmt.Sprintf("%s_public", chelpers.GetLobbyRoom(lobbyid))
+}
+
+func (e *PlayerDisc) Handle() {
+	slot := &models.LobbySlot{}
+	lobbyid := e.LobbyId
+
+	player, tperr := models.GetPlayerBySteamId(e.SteamId)
+	if tperr != nil {
+		helpers.Logger.Warning("playerDisc: %s (%s)", tperr.Error(), e.SteamId)
+		return
+	}
+
+	db.DB.Where("player_id = ? AND lobby_id = ?", player.ID, lobbyid).First(slot)
+	if err := helpers.LockRecord(slot.ID, slot); err != nil {
+		helpers.Logger.Warning("playerDisc: couldn't lock the slot of %s: %s", e.SteamId, err.Error())
+		return
+	}
+	slot.InGame = false
+	db.DB.Save(slot)
+	helpers.UnlockRecord(slot.ID, slot)
+	broadcaster.SendMessageToRoom(publicRoom(lobbyid),
+		"sendNotification", fmt.Sprintf("%s has disconected from the server .",
+			player.Name))
+	go func() {
+		t := time.After(time.Minute * 2)
+		<-t
+		lobby, tperr := models.GetLobbyById(lobbyid)
+		if tperr != nil {
+			return
+		}
+		slot := &models.LobbySlot{}
+		db.DB.Where("player_id = ? AND lobby_id = ?", player.ID, lobbyid).First(slot)
+		if slot.ID == 0 || slot.InGame {
+			return
+		}
+
+		if lobby.State == models.LobbyStateInProgress {
+			_, tperr = models.NewSubstitute(lobby, player, models.SubReasonDisconnected)
+		} else if tperr = models.LockLobby(lobby.ID); tperr == nil {
+			tperr = lobby.RemovePlayer(player)
+			models.UnlockLobby(lobby.ID)
+		}
+		if tperr != nil {
+			helpers.Logger.Warning("Couldn't remove %s from lobby #%d: %s", player.SteamId, lobbyid, tperr.Error())
+			return
+		}
+		broadcaster.SendMessage(player.SteamId, "sendNotification",
+			"You have been removed from the lobby.")
+
+	}()
+}
+
+func (e *PlayerConn) Handle() {
+	slot := &models.LobbySlot{}
+
+	player, tperr := models.GetPlayerBySteamId(e.SteamId)
+	if tperr != nil {
+		helpers.Logger.Warning("playerConn: %s (%s)", tperr.Error(), e.SteamId)
+		return
+	}
+
+	err := db.DB.Where("player_id = ? AND lobby_id = ?", player.ID, e.LobbyId).First(slot).Error
+	if err == nil { //else, player isn't in the lobby, will be kicked by Pauling
+		if err := helpers.LockRecord(slot.ID, slot); err != nil {
+			helpers.Logger.Warning("playerConn: couldn't lock the slot of %s: %s", e.SteamId, err.Error())
+			return
+		}
+		slot.InGame = true
+		db.DB.Save(slot)
+		helpers.UnlockRecord(slot.ID, slot)
+	}
+}
+
+func (e *PlayerRep) Handle() {
+	player, tperr := models.GetPlayerBySteamId(e.SteamId)
+	if tperr != nil {
+		helpers.Logger.Warning("playerRep: %s (%s)", tperr.Error(), e.SteamId)
+		return
+	}
+
+	lobby, tperr := models.GetLobbyById(e.LobbyId)
+	if tperr != nil {
+		helpers.Logger.Warning("playerRep: %s (lobby #%d)", tperr.Error(), e.LobbyId)
+		return
+	}
+
+	if lobby.State == models.LobbyStateInProgress {
+		_, tperr = models.NewSubstitute(lobby, player, models.SubReasonReported)
+	} else if tperr = models.LockLobby(lobby.ID); tperr == nil {
+		tperr = lobby.RemovePlayer(player)
+		models.UnlockLobby(lobby.ID)
+	}
+	if tperr != nil {
+		helpers.Logger.Warning("Couldn't remove %s from lobby #%d: %s", player.SteamId, lobby.ID, tperr.Error())
+		return
+	}
+	broadcaster.SendMessageToRoom(publicRoom(e.LobbyId),
+		"sendNotification", fmt.Sprintf("%s has been reported.",
+			player.Name))
+}
+
+func closeLobby(lobbyid uint, message string) {
+	lobby, tperr := models.GetLobbyById(lobbyid)
+	if tperr != nil {
+		helpers.Logger.Warning("Lobby #%d: %s", lobbyid, tperr.Error())
+		return
+	}
+
+	if tperr := models.LockLobby(lobby.ID); tperr != nil {
+		helpers.Logger.Warning("Couldn't close lobby #%d: %s", lobbyid, tperr.Error())
+		return
+	}
+	lobby.Close(false, models.TriggerPauling)
+	models.UnlockLobby(lobby.ID)
+	broadcaster.SendMessageToRoom(publicRoom(lobbyid),
+		"sendNotification", message)
+}
+
+func (e *DiscFromServer) Handle() {
+	closeLobby(e.LobbyId, "Disconnected from Server.")
+}
+
+func (e *MatchEnded) Handle() {
+	closeLobby(e.LobbyId, "Lobby Ended.")
+}
+
+func (e *GetServers) Validate() error {
+	return nil
+}
+
+func (e *GetServers) Handle() {
+	var lobbies []*models.Lobby
+	var activeStates = []models.LobbyState{models.LobbyStateWaiting, models.LobbyStateInProgress}
+	db.DB.Model(&models.Lobby{}).Where("state IN (?)", activeStates).Find(&lobbies)
+	for _, lobby := range lobbies {
+		info := models.ServerBootstrap{
+			LobbyId: lobby.ID,
+			Info:    lobby.ServerInfo,
+		}
+		for _, player := range lobby.BannedPlayers {
+			info.BannedPlayers = append(info.BannedPlayers, player.SteamId)
+		}
+		for _, slot := range lobby.Slots {
+			var player models.Player
+			db.DB.Find(&player, slot.PlayerId)
+			info.Players = append(info.Players, player.SteamId)
+		}
+		models.SetupVerifier(&info)
+	}
+}
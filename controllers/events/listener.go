@@ -0,0 +1,48 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+)
+
+var startListener = &sync.Once{}
+
+func StartListener() {
+	if config.Constants.ServerMockUp {
+		return
+	}
+	startListener.Do(func() {
+		go listener()
+		helpers.Logger.Debug("Listening for events on Pauling")
+	})
+}
+
+// Pauling holds each WaitEvent call open until it has an event to send, so
+// events are handled as soon as they happen.
+func listener() {
+	for {
+		event, err := models.WaitEvent()
+
+		if err == models.ErrPaulingDown {
+			// the client reconnects on its own, wait for it
+			time.Sleep(time.Second)
+			continue
+		} else if err != nil {
+			helpers.Logger.Warning("Failed to get event from Pauling: %s", err.Error())
+			time.Sleep(time.Second)
+			continue
+		}
+
+		if _, empty := event["empty"]; !empty {
+			Dispatch(event)
+		}
+	}
+}
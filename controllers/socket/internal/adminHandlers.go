@@ -6,11 +6,13 @@ package handler
 
 import (
 	"net/http"
+	"time"
 
 	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
 	db "github.com/TF2Stadium/Helen/database"
 	"github.com/TF2Stadium/Helen/helpers"
 	"github.com/TF2Stadium/Helen/models"
+	"github.com/bitly/go-simplejson"
 	"github.com/googollee/go-socket.io"
 	"reflect"
 )
@@ -59,7 +61,9 @@ func AdminChangeRole(so socketio.Socket) func(string) string {
 
 			currPlayer, _ := chelpers.GetPlayerSocket(so.Id())
 
-			models.LogAdminAction(currPlayer.ID, helpers.ActionChangeRole, otherPlayer.ID)
+			models.LogAdminChange(currPlayer.ID, helpers.ActionChangeRole, otherPlayer.ID,
+				map[string]string{"role": helpers.RoleNames[otherPlayer.Role]},
+				map[string]string{"role": helpers.RoleNames[role]})
 
 			// actual change happens
 			otherPlayer.Role = role
@@ -76,3 +80,208 @@ func AdminChangeRole(so socketio.Socket) func(string) string {
 			return chelpers.BuildEmptySuccessString()
 		})
 }
+
+var adminPaulingStatusFilter = chelpers.FilterParams{
+	Action:      helpers.ActionViewPaulingStatus,
+	FilterLogin: true,
+}
+
+func AdminPaulingStatus(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminPaulingStatusFilter,
+		func(_ map[string]interface{}) string {
+			status := models.GetPaulingStatus()
+
+			j := simplejson.New()
+			j.Set("state", models.PaulingStateNames[status.State])
+			j.Set("since", status.Since.Unix())
+			j.Set("lastError", status.LastError)
+			j.Set("attempts", status.Attempts)
+			j.Set("queued", status.Queued)
+
+			bytes, _ := chelpers.BuildSuccessJSON(j).Encode()
+			return string(bytes)
+		})
+}
+
+// ActionBanPlayer is 0, which FilterRequest takes as no action at all, so the
+// ban handlers check it themselves
+func notAllowedToBan(so socketio.Socket) bool {
+	return !chelpers.CanSocket(so.Id(), helpers.ActionBanPlayer)
+}
+
+var adminBanPlayerFilter = chelpers.FilterParams{
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"steamid":  chelpers.Param{Kind: reflect.String},
+		"type":     chelpers.Param{Kind: reflect.String, In: chelpers.BanTypeList},
+		"duration": chelpers.Param{Kind: reflect.Int}, // seconds
+		"reason":   chelpers.Param{Kind: reflect.String},
+	},
+}
+
+func AdminBanPlayer(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminBanPlayerFilter,
+		func(params map[string]interface{}) string {
+			if notAllowedToBan(so) {
+				bytes, _ := chelpers.BuildFailureJSON("You are not authorized to perform this action.", 0).Encode()
+				return string(bytes)
+			}
+
+			mod, _ := chelpers.GetPlayerSocket(so.Id())
+			duration := time.Duration(params["duration"].(int)) * time.Second
+			ban, tperr := chelpers.BanPlayer(mod, params["steamid"].(string), params["type"].(string),
+				duration, params["reason"].(string))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			bytes, _ := chelpers.BuildSuccessJSON(models.DecoratePlayerBanJSON(ban)).Encode()
+			return string(bytes)
+		})
+}
+
+var adminUnbanPlayerFilter = chelpers.FilterParams{
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"steamid": chelpers.Param{Kind: reflect.String},
+		"type":    chelpers.Param{Kind: reflect.String, In: chelpers.BanTypeList},
+	},
+}
+
+func AdminUnbanPlayer(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminUnbanPlayerFilter,
+		func(params map[string]interface{}) string {
+			if notAllowedToBan(so) {
+				bytes, _ := chelpers.BuildFailureJSON("You are not authorized to perform this action.", 0).Encode()
+				return string(bytes)
+			}
+
+			mod, _ := chelpers.GetPlayerSocket(so.Id())
+			tperr := chelpers.UnbanPlayer(mod, params["steamid"].(string), params["type"].(string))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+			return chelpers.BuildEmptySuccessString()
+		})
+}
+
+var adminGetBansFilter = chelpers.FilterParams{
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"steamid": chelpers.Param{Kind: reflect.String},
+		"all":     chelpers.Param{Kind: reflect.Bool, Default: false},
+	},
+}
+
+func AdminGetBans(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminGetBansFilter,
+		func(params map[string]interface{}) string {
+			if notAllowedToBan(so) {
+				bytes, _ := chelpers.BuildFailureJSON("You are not authorized to perform this action.", 0).Encode()
+				return string(bytes)
+			}
+
+			bans, tperr := chelpers.GetPlayerBans(params["steamid"].(string), !params["all"].(bool))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			bytes, _ := chelpers.BuildSuccessJSON(bans).Encode()
+			return string(bytes)
+		})
+}
+
+var adminGetLogFilter = chelpers.FilterParams{
+	Action:      helpers.ActionViewAdminLog,
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"actor":  chelpers.Param{Kind: reflect.String, Default: ""},
+		"target": chelpers.Param{Kind: reflect.String, Default: ""},
+		"action": chelpers.Param{Kind: reflect.String, Default: ""},
+		"since":  chelpers.Param{Kind: reflect.Int, Default: 0},
+		"until":  chelpers.Param{Kind: reflect.Int, Default: 0},
+		"offset": chelpers.Param{Kind: reflect.Int, Default: 0},
+		"limit":  chelpers.Param{Kind: reflect.Int, Default: models.AdminLogMaxPageSize},
+	},
+}
+
+func AdminGetLog(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminGetLogFilter,
+		func(params map[string]interface{}) string {
+			query, tperr := chelpers.BuildAdminLogQuery(params["actor"].(string), params["target"].(string),
+				params["action"].(string), int64(params["since"].(int)), int64(params["until"].(int)))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+			query.Offset = params["offset"].(int)
+			query.Limit = params["limit"].(int)
+
+			entries, err := models.GetAdminLog(query)
+			if err != nil {
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
+			}
+
+			bytes, _ := chelpers.BuildSuccessJSON(models.DecorateAdminLogJSON(entries)).Encode()
+			return string(bytes)
+		})
+}
+
+var adminGetPermissionsFilter = chelpers.FilterParams{
+	Action:      helpers.ActionManagePermissions,
+	FilterLogin: true,
+}
+
+func AdminGetPermissions(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminGetPermissionsFilter,
+		func(_ map[string]interface{}) string {
+			bytes, _ := chelpers.BuildSuccessJSON(models.DecoratePermissionsJSON()).Encode()
+			return string(bytes)
+		})
+}
+
+var adminSetPermissionFilter = chelpers.FilterParams{
+	Action:      helpers.ActionManagePermissions,
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"role":    chelpers.Param{Kind: reflect.String},
+		"action":  chelpers.Param{Kind: reflect.String},
+		"allowed": chelpers.Param{Kind: reflect.Bool},
+	},
+}
+
+func AdminSetPermission(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminSetPermissionFilter,
+		func(params map[string]interface{}) string {
+			role, ok := helpers.RoleMap[params["role"].(string)]
+			if !ok {
+				bytes, _ := chelpers.BuildFailureJSON("Invalid role parameter", 0).Encode()
+				return string(bytes)
+			}
+			actionName := params["action"].(string)
+			action, ok := helpers.ActionByName(actionName)
+			if !ok {
+				bytes, _ := chelpers.BuildFailureJSON("Invalid action parameter", 0).Encode()
+				return string(bytes)
+			}
+
+			before := role.Can(action)
+			allowed := params["allowed"].(bool)
+			if tperr := models.SetPermission(role, action, allowed); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			player, _ := chelpers.GetPlayerSocket(so.Id())
+			models.LogCustomAdminChange(player.ID, helpers.ActionNames[helpers.ActionManagePermissions], 0,
+				map[string]interface{}{"role": params["role"], "action": actionName, "allowed": before},
+				map[string]interface{}{"role": params["role"], "action": actionName, "allowed": allowed})
+
+			bytes, _ := chelpers.BuildSuccessJSON(models.DecoratePermissionsJSON()).Encode()
+			return string(bytes)
+		})
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"reflect"
+
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/googollee/go-socket.io"
+)
+
+var playerBanAppealFilter = chelpers.FilterParams{
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"banId":   chelpers.Param{Kind: reflect.Uint},
+		"message": chelpers.Param{Kind: reflect.String},
+	},
+	RateLimit: chelpers.RateLimit{
+		Name:       "playerBanAppeal",
+		PerSteamId: helpers.Rate{PerMinute: 2, Burst: 2},
+	},
+}
+
+func PlayerBanAppeal(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, playerBanAppealFilter,
+		func(params map[string]interface{}) string {
+			player, err := chelpers.GetPlayerSocket(so.Id())
+			if err != nil {
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
+			}
+
+			appeal, tperr := player.AppealBan(params["banId"].(uint), params["message"].(string))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+			appeal.Player = *player
+
+			bytes, _ := chelpers.BuildSuccessJSON(models.DecorateBanAppealJSON(appeal)).Encode()
+			return string(bytes)
+		})
+}
+
+var adminGetAppealsFilter = chelpers.FilterParams{
+	FilterLogin: true,
+}
+
+func AdminGetAppeals(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminGetAppealsFilter,
+		func(_ map[string]interface{}) string {
+			if notAllowedToBan(so) {
+				bytes, _ := chelpers.BuildFailureJSON("You are not authorized to perform this action.", 0).Encode()
+				return string(bytes)
+			}
+
+			appeals, err := models.GetOpenAppeals()
+			if err != nil {
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
+			}
+
+			bytes, _ := chelpers.BuildSuccessJSON(models.DecorateBanAppealListJSON(appeals)).Encode()
+			return string(bytes)
+		})
+}
+
+var adminAppealResolveFilter = chelpers.FilterParams{
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"id":       chelpers.Param{Kind: reflect.Uint},
+		"accept":   chelpers.Param{Kind: reflect.Bool},
+		"response": chelpers.Param{Kind: reflect.String, Default: ""},
+	},
+}
+
+// Accepting an appeal lifts the ban, rejecting it leaves it as is
+func AdminAppealResolve(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminAppealResolveFilter,
+		func(params map[string]interface{}) string {
+			if notAllowedToBan(so) {
+				bytes, _ := chelpers.BuildFailureJSON("You are not authorized to perform this action.", 0).Encode()
+				return string(bytes)
+			}
+
+			appeal, tperr := models.GetBanAppealById(params["id"].(uint))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			mod, _ := chelpers.GetPlayerSocket(so.Id())
+			response := params["response"].(string)
+			action := "ActionRejectAppeal"
+			if params["accept"].(bool) {
+				action = "ActionAcceptAppeal"
+				tperr = appeal.Accept(mod, response)
+			} else {
+				tperr = appeal.Reject(mod, response)
+			}
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+			models.LogCustomAdminChange(mod.ID, action, appeal.PlayerID,
+				map[string]interface{}{"appeal": appeal.ID, "ban": appeal.BanID, "state": "open"},
+				map[string]interface{}{"appeal": appeal.ID, "ban": appeal.BanID,
+					"state": models.AppealStateNames[appeal.State], "response": response})
+
+			bytes, _ := models.DecorateBanAppealJSON(appeal).Encode()
+			broadcaster.SendMessage(appeal.Player.SteamId, "banAppealResolved", string(bytes))
+
+			return chelpers.BuildEmptySuccessString()
+		})
+}
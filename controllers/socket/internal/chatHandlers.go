@@ -54,14 +54,23 @@ func ChatSend(so socketio.Socket) func(string) string {
 				room, _ = strconv.Atoi(config.Constants.GlobalChatRoom)
 			}
 
-			chatMessage := simplejson.New()
-			// TODO send proper timestamps
-			chatMessage.Set("timestamp", time.Now().Unix())
-			chatMessage.Set("message", message)
-			chatMessage.Set("room", room)
-
-			chatMessage.Set("player", models.DecoratePlayerSummaryJson(player))
-			bytes, _ := chatMessage.Encode()
+			if tperr := player.CanChat(room); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+			if tperr := player.CheckChatSpam(room, message); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			message = chelpers.FilterChatMessage(message)
+			chatMessage := models.NewChatMessage(message, room, player)
+			if err := chatMessage.Save(); err != nil {
+				helpers.Logger.Warning("Failed to save chat message: %s", err.Error())
+			}
+			chatMessage.Player = *player
+
+			bytes, _ := models.DecorateChatMessageJSON(chatMessage).Encode()
 			broadcaster.SendMessageToRoom(fmt.Sprintf("%s_public",
 				chelpers.GetLobbyRoom(uint(room))),
 				"chatReceive", string(bytes))
@@ -69,8 +78,179 @@ func ChatSend(so socketio.Socket) func(string) string {
 			resp, _ := chelpers.BuildSuccessJSON(simplejson.New()).Encode()
 
 			chelpers.LogChat(uint(room), player.Name, message)
-
-			chelpers.AddScrollbackMessage(uint(room), string(bytes))
 			return string(resp)
 		})
 }
+
+var chatHistoryGetFilter = chelpers.FilterParams{
+	Params: map[string]chelpers.Param{
+		"room":   chelpers.Param{Kind: reflect.Int},
+		"before": chelpers.Param{Kind: reflect.Uint, Default: uint(0)},
+		"limit":  chelpers.Param{Kind: reflect.Int, Default: models.ChatScrollbackSize},
+	},
+	RateLimit: chelpers.RateLimit{
+		Name:      "chatHistoryGet",
+		PerSocket: helpers.Rate{PerMinute: 30, Burst: 10},
+	},
+}
+
+// Pages backwards through a room's messages, "before" is the ID of the
+// oldest message the client has
+func ChatHistoryGet(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, chatHistoryGetFilter,
+		func(params map[string]interface{}) string {
+			room := params["room"].(int)
+			before := params["before"].(uint)
+			limit := params["limit"].(int)
+
+			if limit <= 0 || limit > models.ChatMaxPageSize {
+				limit = models.ChatMaxPageSize
+			}
+
+			if room > 0 {
+				lobby, tperr := models.GetLobbyById(uint(room))
+				if tperr != nil {
+					bytes, _ := tperr.ErrorJSON().Encode()
+					return string(bytes)
+				}
+
+				player, _ := models.GetPlayerBySteamId(chelpers.GetSteamId(so.Id()))
+				if tperr = lobby.CheckAccess(player, ""); tperr != nil {
+					bytes, _ := tperr.ErrorJSON().Encode()
+					return string(bytes)
+				}
+			} else {
+				room, _ = strconv.Atoi(config.Constants.GlobalChatRoom)
+			}
+
+			messages, err := models.GetRoomMessages(room, before, limit)
+			if err != nil {
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
+			}
+
+			var list []*simplejson.Json
+			for _, message := range messages {
+				list = append(list, models.DecorateChatMessageJSON(message))
+			}
+			j := simplejson.New()
+			j.Set("room", room)
+			j.Set("messages", list)
+
+			bytes, _ := chelpers.BuildSuccessJSON(j).Encode()
+			return string(bytes)
+		})
+}
+
+var chatDeleteFilter = chelpers.FilterParams{
+	Action:      helpers.ActionChatDelete,
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"id": chelpers.Param{Kind: reflect.Uint},
+	},
+}
+
+func ChatDelete(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, chatDeleteFilter,
+		func(params map[string]interface{}) string {
+			message, tperr := models.GetChatMessageById(params["id"].(uint))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			if err := message.Delete(); err != nil {
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
+			}
+
+			player, _ := chelpers.GetPlayerSocket(so.Id())
+			// RelID is the author, like the other chat moderation actions
+			models.LogAdminChange(player.ID, helpers.ActionChatDelete, message.PlayerID,
+				map[string]interface{}{"id": message.ID, "room": message.Room, "message": message.Message}, nil)
+
+			deleted := simplejson.New()
+			deleted.Set("id", message.ID)
+			deleted.Set("room", message.Room)
+			bytes, _ := deleted.Encode()
+			broadcaster.SendMessageToRoom(fmt.Sprintf("%s_public",
+				chelpers.GetLobbyRoom(uint(message.Room))),
+				"chatDelete", string(bytes))
+
+			return chelpers.BuildEmptySuccessString()
+		})
+}
+
+var chatMuteFilter = chelpers.FilterParams{
+	Action:      helpers.ActionChatMute,
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"steamid": chelpers.Param{Kind: reflect.String},
+		"room":    chelpers.Param{Kind: reflect.Int},
+		"minutes": chelpers.Param{Kind: reflect.Int},
+		"reason":  chelpers.Param{Kind: reflect.String, Default: ""},
+	},
+}
+
+func ChatMute(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, chatMuteFilter,
+		func(params map[string]interface{}) string {
+			minutes := params["minutes"].(int)
+			if minutes <= 0 {
+				bytes, _ := chelpers.BuildFailureJSON(`Paramter "minutes" not valid`, 0).Encode()
+				return string(bytes)
+			}
+
+			target, tperr := models.GetPlayerBySteamId(params["steamid"].(string))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			player, _ := chelpers.GetPlayerSocket(so.Id())
+			room := params["room"].(int)
+			until := time.Now().Add(time.Duration(minutes) * time.Minute)
+			if err := target.MuteInRoom(room, until, params["reason"].(string), player); err != nil {
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
+			}
+			models.LogAdminChange(player.ID, helpers.ActionChatMute, target.ID, nil,
+				map[string]interface{}{"room": room, "until": until.Unix(), "reason": params["reason"].(string)})
+
+			broadcaster.SendMessage(target.SteamId, "sendNotification",
+				fmt.Sprintf("You've been muted for %d minutes.", minutes))
+			return chelpers.BuildEmptySuccessString()
+		})
+}
+
+var chatUnmuteFilter = chelpers.FilterParams{
+	Action:      helpers.ActionChatMute,
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"steamid": chelpers.Param{Kind: reflect.String},
+		"room":    chelpers.Param{Kind: reflect.Int},
+	},
+}
+
+func ChatUnmute(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, chatUnmuteFilter,
+		func(params map[string]interface{}) string {
+			target, tperr := models.GetPlayerBySteamId(params["steamid"].(string))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			room := params["room"].(int)
+			if err := target.UnmuteInRoom(room); err != nil {
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
+			}
+
+			// the mute going away, ChatMute logs it being made
+			player, _ := chelpers.GetPlayerSocket(so.Id())
+			models.LogAdminChange(player.ID, helpers.ActionChatMute, target.ID,
+				map[string]interface{}{"room": room}, nil)
+			return chelpers.BuildEmptySuccessString()
+		})
+}
@@ -33,7 +33,7 @@ func DebugLobbyFill(so socketio.Socket) func(string) string {
 			lobby, _ := models.GetLobbyById(id)
 			var players []*models.Player
 
-			for i := 1; i < int(lobby.Type)*2; i++ {
+			for i := 1; i < lobby.Type.Format().NumSlots(); i++ {
 				steamid := "DEBUG" + strconv.FormatUint(uint64(time.Now().Unix()), 10) + strconv.Itoa(i)
 
 				player, _ := models.NewPlayer(steamid)
@@ -43,11 +43,14 @@ func DebugLobbyFill(so socketio.Socket) func(string) string {
 				lobby.AddPlayer(player, i)
 			}
 
-			lobby.State = models.LobbyStateReadyingUp
+			if tperr := lobby.SetState(models.LobbyStateReadyingUp, models.TriggerDebug); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
 			lobby.Save()
 			room := fmt.Sprintf("%s_public", chelpers.GetLobbyRoom(lobby.ID))
 			broadcaster.SendMessageToRoom(room, "lobbyReadyUp", "")
-			lobby.ReadyUpTimeoutCheck()
+			lobby.StartReadyUpTimer()
 			bytes, _ := chelpers.BuildSuccessJSON(simplejson.New()).Encode()
 			return string(bytes)
 
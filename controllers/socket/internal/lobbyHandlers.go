@@ -7,8 +7,10 @@ package handler
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/TF2Stadium/Helen/controllers/broadcaster"
 	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
@@ -29,66 +31,164 @@ var lobbyCreateFilters = chelpers.FilterParams{
 
 		"type": chelpers.Param{
 			Kind: reflect.String,
-			In:   []string{"highlander", "sixes", "debug"}},
+			In:   models.FormatNames()},
 		"league": chelpers.Param{
 			Kind: reflect.String,
 			In:   []string{"etf2l", "ugc"}},
 
-		"server": chelpers.Param{Kind: reflect.String},
+		"region": chelpers.Param{Kind: reflect.String, Default: ""},
 
-		"rconpwd":        chelpers.Param{Kind: reflect.String},
 		"whitelist":      chelpers.Param{Kind: reflect.Uint},
 		"mumbleRequired": chelpers.Param{Kind: reflect.Bool},
+
+		"minHours":          chelpers.Param{Kind: reflect.Int, Default: 0},
+		"minLobbies":        chelpers.Param{Kind: reflect.Int, Default: 0},
+		"regions":           chelpers.Param{Kind: reflect.Slice, Default: []interface{}{}},
+		"classRequirements": chelpers.Param{Kind: reflect.Map, Default: map[string]interface{}{}},
+
+		"password": chelpers.Param{Kind: reflect.String, Default: ""},
+		"invites":  chelpers.Param{Kind: reflect.Slice, Default: []interface{}{}},
+	},
+	RateLimit: chelpers.RateLimit{
+		Name:       "lobbyCreate",
+		PerSocket:  helpers.Rate{PerMinute: 4, Burst: 2},
+		PerSteamId: helpers.Rate{PerMinute: 4, Burst: 2},
 	},
 }
 
+// reads a number out of a decoded JSON object
+func jsonInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		i, err := n.Int64()
+		return int(i), err == nil
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// Parses {"medic": {"hours": 1000, "lobbies": 10}, ...}
+func parseClassRequirements(format *models.Format, raw map[string]interface{}) ([]models.Requirement, *helpers.TPError) {
+	invalid := helpers.NewTPError(`Paramter "classRequirements" not valid`, 0)
+	var reqs []models.Requirement
+
+	for class, value := range raw {
+		obj, ok := value.(map[string]interface{})
+		if !ok || !format.HasClass(class) {
+			return nil, invalid
+		}
+
+		req := models.Requirement{Class: class}
+		if v, ok := obj["hours"]; ok {
+			if req.Hours, ok = jsonInt(v); !ok || req.Hours < 0 {
+				return nil, invalid
+			}
+		}
+		if v, ok := obj["lobbies"]; ok {
+			if req.Lobbies, ok = jsonInt(v); !ok || req.Lobbies < 0 {
+				return nil, invalid
+			}
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
 func LobbyCreate(so socketio.Socket) func(string) string {
 	return chelpers.FilterRequest(so, lobbyCreateFilters,
 		func(params map[string]interface{}) string {
 
 			player, _ := models.GetPlayerBySteamId(chelpers.GetSteamId(so.Id()))
+			if tperr := player.CheckBan(models.PlayerBanCreate); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
 
 			mapName := params["mapName"].(string)
 			lobbytypestring := params["type"].(string)
 			league := params["league"].(string)
-			server := params["server"].(string)
-			rconPwd := params["rconpwd"].(string)
+			region := params["region"].(string)
 			whitelist := int(params["whitelist"].(uint))
 			mumble := params["mumbleRequired"].(bool)
 
-			var playermap = map[string]models.LobbyType{
-				"debug":      models.LobbyTypeDebug,
-				"sixes":      models.LobbyTypeSixes,
-				"highlander": models.LobbyTypeHighlander,
+			format, _ := models.GetFormatByName(lobbytypestring)
+
+			minHours := params["minHours"].(int)
+			minLobbies := params["minLobbies"].(int)
+			if minHours < 0 || minLobbies < 0 {
+				bytes, _ := chelpers.BuildFailureJSON("Requirements can't be negative.", 0).Encode()
+				return string(bytes)
+			}
+
+			var regions []string
+			for _, r := range params["regions"].([]interface{}) {
+				str, ok := r.(string)
+				if !ok || str == "" {
+					bytes, _ := chelpers.BuildFailureJSON(`Paramter "regions" not valid`, 0).Encode()
+					return string(bytes)
+				}
+				regions = append(regions, str)
+			}
+
+			classReqs, tperr := parseClassRequirements(format, params["classRequirements"].(map[string]interface{}))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
 			}
 
-			lobbytype, _ := playermap[lobbytypestring]
+			var invites []string
+			for _, steamid := range params["invites"].([]interface{}) {
+				str, ok := steamid.(string)
+				if !ok || str == "" {
+					bytes, _ := chelpers.BuildFailureJSON(`Paramter "invites" not valid`, 0).Encode()
+					return string(bytes)
+				}
+				invites = append(invites, str)
+			}
 
 			randBytes := make([]byte, 6)
 			rand.Read(randBytes)
 			serverPwd := base64.URLEncoding.EncodeToString(randBytes)
 
-			//TODO what if playermap[lobbytype] is nil?
-			info := models.ServerRecord{
-				Host:           server,
-				RconPassword:   rconPwd,
-				ServerPassword: serverPwd}
-			err := models.VerifyInfo(info)
+			server, tperr := models.ReserveServer(format.Type, region)
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			record, err := server.Record(serverPwd)
 			if err != nil {
-				return err.Error()
+				models.ReleaseServer(server.ID)
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
 			}
 
-			lob := models.NewLobby(mapName, lobbytype, league, info, whitelist, mumble)
+			lob := models.NewLobby(mapName, format.Type, league, record, whitelist, mumble)
 			lob.CreatedBySteamID = player.SteamId
+			lob.SetRegions(regions)
+			lob.SetPassword(params["password"].(string))
 			lob.Save()
+			server.AssignLobby(lob)
+
+			for _, steamid := range invites {
+				lob.Invite(steamid)
+			}
+			if minHours != 0 || minLobbies != 0 {
+				lob.SetRequirement("", minHours, minLobbies)
+			}
+			for _, req := range classReqs {
+				lob.SetRequirement(req.Class, req.Hours, req.Lobbies)
+			}
 			err = lob.SetupServer()
 
 			if err != nil {
+				lob.Close(false, models.TriggerLobbyCreate)
 				bytes, _ := err.(*helpers.TPError).ErrorJSON().Encode()
 				return string(bytes)
 			}
 
-			lob.State = models.LobbyStateWaiting
+			lob.SetState(models.LobbyStateWaiting, models.TriggerLobbyCreate)
 			lob.Save()
 			lobby_id := simplejson.New()
 			lobby_id.Set("id", lob.ID)
@@ -97,34 +197,6 @@ func LobbyCreate(so socketio.Socket) func(string) string {
 		})
 }
 
-var serverVerifyFilters = chelpers.FilterParams{
-	Action:      authority.AuthAction(0),
-	FilterLogin: true,
-	Params: map[string]chelpers.Param{
-		"server":  {Kind: reflect.String},
-		"rconpwd": {Kind: reflect.String},
-	},
-}
-
-func ServerVerify(so socketio.Socket) func(string) string {
-	return chelpers.FilterRequest(so, serverVerifyFilters,
-		func(params map[string]interface{}) string {
-			info := models.ServerRecord{
-				Host:         params["server"].(string),
-				RconPassword: params["rconpwd"].(string),
-			}
-			err := models.VerifyInfo(info)
-			if err != nil {
-				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
-				return string(bytes)
-			}
-
-			bytes, _ := chelpers.BuildSuccessJSON(simplejson.New()).Encode()
-			return string(bytes)
-
-		})
-}
-
 var lobbyCloseFilters = chelpers.FilterParams{
 	Action:      authority.AuthAction(0),
 	FilterLogin: true,
@@ -146,7 +218,7 @@ func LobbyClose(so socketio.Socket) func(string) string {
 				return string(bytes)
 			}
 
-			if player.SteamId != lob.CreatedBySteamID && player.Role != helpers.RoleAdmin {
+			if player.SteamId != lob.CreatedBySteamID && !chelpers.CanSocket(so.Id(), helpers.ActionCloseLobby) {
 				bytes, _ := chelpers.BuildFailureJSON("Player not authorized to close lobby.", 1).Encode()
 				return string(bytes)
 			}
@@ -156,9 +228,14 @@ func LobbyClose(so socketio.Socket) func(string) string {
 				return string(bytes)
 			}
 
-			helpers.LockRecord(lob.ID, lob)
-			lob.Close(true)
-			helpers.UnlockRecord(lob.ID, lob)
+			if tperr = models.LockLobby(lob.ID); tperr == nil {
+				tperr = lob.Close(true, models.PlayerTrigger(player))
+				models.UnlockLobby(lob.ID)
+			}
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
 			chelpers.StopLogger(lobbyid)
 			models.BroadcastLobbyList() // has to be done manually for now
 
@@ -172,9 +249,15 @@ var lobbyJoinFilters = chelpers.FilterParams{
 	Action:      authority.AuthAction(0),
 	FilterLogin: true,
 	Params: map[string]chelpers.Param{
-		"id":    chelpers.Param{Kind: reflect.Uint},
-		"class": chelpers.Param{Kind: reflect.String},
-		"team":  chelpers.Param{Kind: reflect.String},
+		"id":       chelpers.Param{Kind: reflect.Uint},
+		"class":    chelpers.Param{Kind: reflect.String},
+		"team":     chelpers.Param{Kind: reflect.String},
+		"password": chelpers.Param{Kind: reflect.String, Default: ""},
+	},
+	RateLimit: chelpers.RateLimit{
+		Name:       "lobbyJoin",
+		PerSocket:  helpers.Rate{PerMinute: 30, Burst: 10},
+		PerSteamId: helpers.Rate{PerMinute: 30, Burst: 10},
 	},
 }
 
@@ -187,6 +270,10 @@ func LobbyJoin(so socketio.Socket) func(string) string {
 				bytes, _ := tperr.ErrorJSON().Encode()
 				return string(bytes)
 			}
+			if tperr = player.CheckBan(models.PlayerBanJoin); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
 
 			lobbyid := params["id"].(uint)
 			classString := params["class"].(string)
@@ -198,6 +285,11 @@ func LobbyJoin(so socketio.Socket) func(string) string {
 				return string(bytes)
 			}
 
+			if tperr = lob.CheckAccess(player, params["password"].(string)); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
 			//Check if player is in the same lobby
 			var sameLobby bool
 			if id, err := player.GetLobbyId(); err == nil && id == lobbyid {
@@ -210,8 +302,11 @@ func LobbyJoin(so socketio.Socket) func(string) string {
 				return string(bytes)
 			}
 
-			helpers.LockRecord(lob.ID, lob)
-			defer helpers.UnlockRecord(lob.ID, lob)
+			if tperr := models.LockLobby(lob.ID); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+			defer models.UnlockLobby(lob.ID)
 			tperr = lob.AddPlayer(player, slot)
 
 			if tperr != nil {
@@ -220,17 +315,16 @@ func LobbyJoin(so socketio.Socket) func(string) string {
 			}
 
 			if !sameLobby {
-				chelpers.AfterLobbyJoin(so, lob, player)
+				// every tab the player has open
+				for _, socket := range broadcaster.GetSockets(player.SteamId) {
+					chelpers.AfterLobbyJoin(socket, lob, player)
+				}
 			}
 
-			if lob.IsFull() {
-				lob.State = models.LobbyStateReadyingUp
+			if lob.IsFull() && lob.SetState(models.LobbyStateReadyingUp, models.PlayerTrigger(player)) == nil {
 				lob.Save()
-				lob.ReadyUpTimeoutCheck()
-				room := fmt.Sprintf("%s_private",
-					chelpers.GetLobbyRoom(lob.ID))
-				broadcaster.SendMessageToRoom(room, "lobbyReadyUp",
-					`{"timeout":30}`)
+				lob.StartReadyUpTimer()
+				chelpers.BroadcastReadyUp(lob)
 				models.BroadcastLobbyList()
 			}
 
@@ -243,7 +337,13 @@ func LobbyJoin(so socketio.Socket) func(string) string {
 var lobbySpectatorJoinFilters = chelpers.FilterParams{
 	FilterLogin: true,
 	Params: map[string]chelpers.Param{
-		"id": chelpers.Param{Kind: reflect.Uint},
+		"id":       chelpers.Param{Kind: reflect.Uint},
+		"password": chelpers.Param{Kind: reflect.String, Default: ""},
+	},
+	RateLimit: chelpers.RateLimit{
+		Name:       "lobbySpectatorJoin",
+		PerSocket:  helpers.Rate{PerMinute: 30, Burst: 10},
+		PerSteamId: helpers.Rate{PerMinute: 30, Burst: 10},
 	},
 }
 
@@ -267,10 +367,16 @@ func LobbySpectatorJoin(so socketio.Socket) func(string) string {
 				return string(bytes)
 			}
 
+			if tperr = lob.CheckAccess(player, params["password"].(string)); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
 			if id, _ := player.GetLobbyId(); id != lobbyid {
-				helpers.LockRecord(lob.ID, lob)
-				tperr = lob.AddSpectator(player)
-				helpers.UnlockRecord(lob.ID, lob)
+				if tperr = models.LockLobby(lob.ID); tperr == nil {
+					tperr = lob.AddSpectator(player)
+					models.UnlockLobby(lob.ID)
+				}
 			}
 
 			bytes, _ := chelpers.BuildSuccessJSON(simplejson.New()).Encode()
@@ -280,7 +386,10 @@ func LobbySpectatorJoin(so socketio.Socket) func(string) string {
 				return string(bytes)
 			}
 
-			chelpers.AfterLobbySpec(so, lob)
+			// every tab the player has open, they all get the snapshot
+			for _, socket := range broadcaster.GetSockets(player.SteamId) {
+				chelpers.AfterLobbySpec(socket, lob)
+			}
 			models.BroadcastLobbyToUser(lob, player.SteamId)
 			return string(bytes)
 		})
@@ -288,7 +397,12 @@ func LobbySpectatorJoin(so socketio.Socket) func(string) string {
 
 var lobbyNoLoginSpectatorJoinFilters = chelpers.FilterParams{
 	Params: map[string]chelpers.Param{
-		"id": chelpers.Param{Kind: reflect.Uint},
+		"id":       chelpers.Param{Kind: reflect.Uint},
+		"password": chelpers.Param{Kind: reflect.String, Default: ""},
+	},
+	RateLimit: chelpers.RateLimit{
+		Name:      "lobbySpectatorJoin",
+		PerSocket: helpers.Rate{PerMinute: 30, Burst: 10},
 	},
 }
 
@@ -303,8 +417,13 @@ func LobbyNoLoginSpectatorJoin(so socketio.Socket) func(string) string {
 				return string(bytes)
 			}
 
+			if err = lobby.CheckAccess(nil, params["password"].(string)); err != nil {
+				bytes, _ := err.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
 			chelpers.AfterLobbySpec(so, lobby)
-			bytes, _ := models.DecorateLobbyDataJSON(lobby, true).Encode()
+			bytes, _ := models.DecorateLobbySnapshotJSON(lobby, models.GetLobbyVersion(lobby.ID)).Encode()
 			so.Emit("lobbyData", string(bytes))
 
 			bytes, _ = chelpers.BuildSuccessJSON(simplejson.New()).Encode()
@@ -312,6 +431,51 @@ func LobbyNoLoginSpectatorJoin(so socketio.Socket) func(string) string {
 		})
 }
 
+var lobbyResyncFilters = chelpers.FilterParams{
+	Params: map[string]chelpers.Param{
+		"id":       chelpers.Param{Kind: reflect.Uint},
+		"version":  chelpers.Param{Kind: reflect.Int, Default: 0},
+		"password": chelpers.Param{Kind: reflect.String, Default: ""},
+	},
+	RateLimit: chelpers.RateLimit{
+		Name:      "lobbyResync",
+		PerSocket: helpers.Rate{PerMinute: 30, Burst: 10},
+	},
+}
+
+// For clients that missed a lobbyDelta. Sends the whole lobby if their
+// version is out of date.
+func LobbyResync(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, lobbyResyncFilters,
+		func(params map[string]interface{}) string {
+			lobby, tperr := models.GetLobbyById(params["id"].(uint))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			var player *models.Player
+			if chelpers.IsLoggedInSocket(so.Id()) {
+				player, _ = models.GetPlayerBySteamId(chelpers.GetSteamId(so.Id()))
+			}
+			if tperr = lobby.CheckAccess(player, params["password"].(string)); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			j := simplejson.New()
+			version := models.GetLobbyVersion(lobby.ID)
+			j.Set("version", version)
+			j.Set("stale", version != params["version"].(int))
+			if version != params["version"].(int) {
+				j.Set("lobby", models.DecorateLobbySnapshotJSON(lobby, version))
+			}
+
+			bytes, _ := chelpers.BuildSuccessJSON(j).Encode()
+			return string(bytes)
+		})
+}
+
 var lobbyKickFilters = chelpers.FilterParams{
 	Action:      authority.AuthAction(0),
 	FilterLogin: true,
@@ -331,7 +495,6 @@ func LobbyKick(so socketio.Socket) func(string) string {
 			var self bool
 
 			selfSteamid := chelpers.GetSteamId(so.Id())
-			// TODO check authorization, currently can kick anyone
 			if steamid == "" {
 				self = true
 				steamid = selfSteamid
@@ -350,16 +513,18 @@ func LobbyKick(so socketio.Socket) func(string) string {
 				return string(bytes)
 			}
 
-			if !self && selfSteamid != lob.CreatedBySteamID {
-				// TODO proper authorization checks
+			if !self && selfSteamid != lob.CreatedBySteamID && !chelpers.CanSocket(so.Id(), helpers.ActionKickFromLobby) {
 				bytes, _ := chelpers.BuildFailureJSON(
 					"Not authorized to remove players", 1).Encode()
 				return string(bytes)
 			}
 
 			_, err := lob.GetPlayerSlot(player)
-			helpers.LockRecord(lob.ID, lob)
-			defer helpers.UnlockRecord(lob.ID, lob)
+			if tperr := models.LockLobby(lob.ID); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+			defer models.UnlockLobby(lob.ID)
 
 			var spec bool
 			if err == nil {
@@ -376,10 +541,12 @@ func LobbyKick(so socketio.Socket) func(string) string {
 				lob.BanPlayer(player)
 			}
 
-			if !spec {
-				chelpers.AfterLobbyLeave(so, lob, player)
-			} else {
-				chelpers.AfterLobbySpecLeave(so, lob)
+			for _, socket := range broadcaster.GetSockets(player.SteamId) {
+				if !spec {
+					chelpers.AfterLobbyLeave(socket, lob, player)
+				} else {
+					chelpers.AfterLobbySpecLeave(socket, lob)
+				}
 			}
 
 			if !self {
@@ -425,17 +592,20 @@ func PlayerReady(so socketio.Socket) func(string) string {
 				return string(bytes)
 			}
 
-			helpers.LockRecord(lobby.ID, lobby)
+			if tperr := models.LockLobby(lobby.ID); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+			defer models.UnlockLobby(lobby.ID)
 			tperr = lobby.ReadyPlayer(player)
-			defer helpers.UnlockRecord(lobby.ID, lobby)
 
 			if tperr != nil {
 				bytes, _ := tperr.ErrorJSON().Encode()
 				return string(bytes)
 			}
 
-			if lobby.IsEveryoneReady() {
-				lobby.State = models.LobbyStateInProgress
+			if lobby.IsEveryoneReady() && lobby.SetState(models.LobbyStateInProgress, models.PlayerTrigger(player)) == nil {
+				lobby.StopReadyUpTimer()
 				lobby.Save()
 				bytes, _ := models.DecorateLobbyConnectJSON(lobby).Encode()
 				room := fmt.Sprintf("%s_private",
@@ -481,9 +651,10 @@ func PlayerUnready(so socketio.Socket) func(string) string {
 				return string(bytes)
 			}
 
-			helpers.LockRecord(lobby.ID, lobby)
-			tperr = lobby.UnreadyPlayer(player)
-			helpers.UnlockRecord(lobby.ID, lobby)
+			if tperr = models.LockLobby(lobby.ID); tperr == nil {
+				tperr = lobby.UnreadyPlayer(player)
+				models.UnlockLobby(lobby.ID)
+			}
 
 			if tperr != nil {
 				bytes, _ := tperr.ErrorJSON().Encode()
@@ -497,16 +668,74 @@ func PlayerUnready(so socketio.Socket) func(string) string {
 
 func RequestLobbyListData(so socketio.Socket) func(string) string {
 	return func(s string) string {
-		var lobbies []models.Lobby
-		db.DB.Where("state = ?", models.LobbyStateWaiting).Order("id desc").Find(&lobbies)
-		list, err := models.DecorateLobbyListData(lobbies)
-		if err != nil {
-			helpers.Logger.Warning("Failed to send lobby list: %s", err.Error())
-		} else {
-			so.Emit("lobbyListData", list)
-		}
+		chelpers.EmitLobbyList(so)
 
 		resp, _ := chelpers.BuildSuccessJSON(simplejson.New()).Encode()
 		return string(resp)
 	}
 }
+
+var lobbySubClaimFilters = chelpers.FilterParams{
+	Action:      authority.AuthAction(0),
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"id": chelpers.Param{Kind: reflect.Uint},
+	},
+	RateLimit: chelpers.RateLimit{
+		Name:       "lobbySubClaim",
+		PerSocket:  helpers.Rate{PerMinute: 10, Burst: 3},
+		PerSteamId: helpers.Rate{PerMinute: 10, Burst: 3},
+	},
+}
+
+func LobbySubClaim(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, lobbySubClaimFilters,
+		func(params map[string]interface{}) string {
+			player, tperr := models.GetPlayerBySteamId(chelpers.GetSteamId(so.Id()))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+			if tperr = player.CheckBan(models.PlayerBanJoin); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			sub, tperr := models.GetSubstituteById(params["id"].(uint))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			if err := helpers.LockRecord(sub.ID, sub); err != nil {
+				bytes, _ := helpers.NewRetryTPError("The substitute is busy, please try again.", models.ErrorCodeLobbyBusy, time.Second).
+					ErrorJSON().Encode()
+				return string(bytes)
+			}
+			// someone else might have claimed it while we were waiting
+			db.DB.First(sub, sub.ID)
+			tperr = sub.Fill(player)
+			helpers.UnlockRecord(sub.ID, sub)
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			lobby, _ := models.GetLobbyById(sub.LobbyID)
+			for _, socket := range broadcaster.GetSockets(player.SteamId) {
+				chelpers.AfterLobbyJoin(socket, lobby, player)
+				chelpers.AfterLobbySpec(socket, lobby)
+			}
+			models.BroadcastLobbyToUser(lobby, player.SteamId)
+
+			bytes, _ := models.DecorateLobbyConnectJSON(lobby).Encode()
+			broadcaster.SendMessage(player.SteamId, "lobbyStart", string(bytes))
+			broadcaster.SendMessageToRoom(fmt.Sprintf("%s_public", chelpers.GetLobbyRoom(lobby.ID)),
+				"sendNotification", fmt.Sprintf("%s is subbing in.", player.Name))
+
+			lobbyid := simplejson.New()
+			lobbyid.Set("id", lobby.ID)
+			bytes, _ = chelpers.BuildSuccessJSON(lobbyid).Encode()
+			return string(bytes)
+		})
+}
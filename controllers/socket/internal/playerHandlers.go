@@ -2,6 +2,7 @@ package handler
 
 import (
 	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/helpers"
 	"github.com/TF2Stadium/Helen/models"
 	"github.com/bitly/go-simplejson"
 	"github.com/googollee/go-socket.io"
@@ -75,6 +76,10 @@ var playerProfileFilter = chelpers.FilterParams{
 	Params: map[string]chelpers.Param{
 		"steamid": chelpers.Param{Kind: reflect.String, Default: ""},
 	},
+	RateLimit: chelpers.RateLimit{
+		Name:      "playerProfile",
+		PerSocket: helpers.Rate{PerMinute: 60, Burst: 10},
+	},
 }
 
 func PlayerProfile(so socketio.Socket) func(string) string {
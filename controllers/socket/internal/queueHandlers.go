@@ -0,0 +1,82 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"reflect"
+
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/bitly/go-simplejson"
+	"github.com/googollee/go-socket.io"
+)
+
+var queueJoinFilters = chelpers.FilterParams{
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"type": chelpers.Param{
+			Kind: reflect.String,
+			In:   models.FormatNames()},
+		"league": chelpers.Param{
+			Kind: reflect.String,
+			In:   []string{"etf2l", "ugc"}},
+		"classes": chelpers.Param{Kind: reflect.Slice},
+	},
+}
+
+func QueueJoin(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, queueJoinFilters,
+		func(params map[string]interface{}) string {
+			player, tperr := models.GetPlayerBySteamId(chelpers.GetSteamId(so.Id()))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+			if tperr = player.CheckBan(models.PlayerBanJoin); tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			format, _ := models.GetFormatByName(params["type"].(string))
+			league := params["league"].(string)
+
+			var classes []string
+			for _, class := range params["classes"].([]interface{}) {
+				str, ok := class.(string)
+				if !ok {
+					bytes, _ := chelpers.BuildFailureJSON(`Paramter "classes" not valid`, 0).Encode()
+					return string(bytes)
+				}
+				classes = append(classes, str)
+			}
+
+			tperr = models.EnqueuePlayer(player, format.Type, league, classes)
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			status, _ := models.GetQueueStatus(player.SteamId)
+			bytes, _ := chelpers.BuildSuccessJSON(chelpers.DecorateQueueStatusJSON(status)).Encode()
+			return string(bytes)
+		})
+}
+
+var queueLeaveFilters = chelpers.FilterParams{
+	FilterLogin: true,
+}
+
+func QueueLeave(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, queueLeaveFilters,
+		func(_ map[string]interface{}) string {
+			if !models.DequeuePlayer(chelpers.GetSteamId(so.Id())) {
+				bytes, _ := chelpers.BuildFailureJSON("Player is not in the queue.", 1).Encode()
+				return string(bytes)
+			}
+
+			bytes, _ := chelpers.BuildSuccessJSON(simplejson.New()).Encode()
+			return string(bytes)
+		})
+}
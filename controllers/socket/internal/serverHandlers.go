@@ -0,0 +1,198 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package handler
+
+import (
+	"reflect"
+
+	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/bitly/go-simplejson"
+	"github.com/googollee/go-socket.io"
+)
+
+func decorateGameServer(server *models.GameServer) *simplejson.Json {
+	j := simplejson.New()
+	j.Set("id", server.ID)
+	j.Set("host", server.Host)
+	j.Set("region", server.Region)
+	j.Set("capacity", server.Capacity)
+	j.Set("health", models.ServerHealthNames[server.Health])
+	j.Set("lastError", server.LastError)
+	j.Set("checkedAt", server.CheckedAt.Unix())
+	j.Set("inUse", server.InUse)
+	j.Set("lobbyId", server.LobbyID)
+	return j
+}
+
+var adminServerAddFilter = chelpers.FilterParams{
+	Action:      helpers.ActionManageServers,
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"server":   chelpers.Param{Kind: reflect.String},
+		"rconpwd":  chelpers.Param{Kind: reflect.String},
+		"region":   chelpers.Param{Kind: reflect.String},
+		"capacity": chelpers.Param{Kind: reflect.Int, Default: 18},
+	},
+	RateLimit: chelpers.RateLimit{
+		Name:       "adminServerAdd",
+		PerSteamId: helpers.Rate{PerMinute: 10, Burst: 3},
+	},
+}
+
+func AdminServerAdd(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminServerAddFilter,
+		func(params map[string]interface{}) string {
+			server, err := models.NewGameServer(params["server"].(string), params["rconpwd"].(string),
+				params["region"].(string), params["capacity"].(int))
+			if err != nil {
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
+			}
+
+			record, err := server.Record("")
+			if err == nil {
+				err = models.VerifyInfo(record)
+			}
+			if err != nil {
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
+			}
+			if err := server.Save(); err != nil {
+				bytes, _ := chelpers.BuildFailureJSON("Server already in the pool.", -1).Encode()
+				return string(bytes)
+			}
+
+			player, _ := chelpers.GetPlayerSocket(so.Id())
+			models.LogAdminAction(player.ID, helpers.ActionManageServers, server.ID)
+
+			bytes, _ := chelpers.BuildSuccessJSON(decorateGameServer(server)).Encode()
+			return string(bytes)
+		})
+}
+
+var adminServerIdFilter = chelpers.FilterParams{
+	Action:      helpers.ActionManageServers,
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"id": chelpers.Param{Kind: reflect.Uint},
+	},
+}
+
+func AdminServerRemove(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminServerIdFilter,
+		func(params map[string]interface{}) string {
+			server, tperr := models.GetGameServerById(params["id"].(uint))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			if server.InUse {
+				bytes, _ := chelpers.BuildFailureJSON("Server is being used by a lobby.", -1).Encode()
+				return string(bytes)
+			}
+			// a soft delete would keep the host taken
+			db.DB.Unscoped().Delete(server)
+
+			player, _ := chelpers.GetPlayerSocket(so.Id())
+			models.LogAdminChange(player.ID, helpers.ActionManageServers, server.ID,
+				map[string]string{"host": server.Host, "region": server.Region}, nil)
+
+			return chelpers.BuildEmptySuccessString()
+		})
+}
+
+var adminServerSetEnabledFilter = chelpers.FilterParams{
+	Action:      helpers.ActionManageServers,
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"id":      chelpers.Param{Kind: reflect.Uint},
+		"enabled": chelpers.Param{Kind: reflect.Bool},
+	},
+}
+
+func AdminServerSetEnabled(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminServerSetEnabledFilter,
+		func(params map[string]interface{}) string {
+			server, tperr := models.GetGameServerById(params["id"].(uint))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			before := map[string]string{"health": models.ServerHealthNames[server.Health]}
+			if params["enabled"].(bool) {
+				// it has to pass a check before lobbies get put on it again
+				server.Health = models.ServerUnhealthy
+				server.Verify()
+			} else {
+				server.Health = models.ServerDisabled
+				db.DB.Model(server).Update("health", server.Health)
+			}
+
+			player, _ := chelpers.GetPlayerSocket(so.Id())
+			models.LogAdminChange(player.ID, helpers.ActionManageServers, server.ID, before,
+				map[string]string{"health": models.ServerHealthNames[server.Health]})
+
+			bytes, _ := chelpers.BuildSuccessJSON(decorateGameServer(server)).Encode()
+			return string(bytes)
+		})
+}
+
+var adminServerCheckFilter = chelpers.FilterParams{
+	Action:      helpers.ActionManageServers,
+	FilterLogin: true,
+	Params: map[string]chelpers.Param{
+		"id": chelpers.Param{Kind: reflect.Uint},
+	},
+	RateLimit: chelpers.RateLimit{
+		Name:       "adminServerCheck",
+		PerSteamId: helpers.Rate{PerMinute: 10, Burst: 3},
+	},
+}
+
+func AdminServerCheck(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminServerCheckFilter,
+		func(params map[string]interface{}) string {
+			server, tperr := models.GetGameServerById(params["id"].(uint))
+			if tperr != nil {
+				bytes, _ := tperr.ErrorJSON().Encode()
+				return string(bytes)
+			}
+
+			server.Verify()
+			bytes, _ := chelpers.BuildSuccessJSON(decorateGameServer(server)).Encode()
+			return string(bytes)
+		})
+}
+
+var adminServerListFilter = chelpers.FilterParams{
+	Action:      helpers.ActionManageServers,
+	FilterLogin: true,
+}
+
+func AdminServerList(so socketio.Socket) func(string) string {
+	return chelpers.FilterRequest(so, adminServerListFilter,
+		func(_ map[string]interface{}) string {
+			servers, err := models.GetGameServers()
+			if err != nil {
+				bytes, _ := chelpers.BuildFailureJSON(err.Error(), -1).Encode()
+				return string(bytes)
+			}
+
+			var list []*simplejson.Json
+			for _, server := range servers {
+				list = append(list, decorateGameServer(server))
+			}
+			j := simplejson.New()
+			j.Set("servers", list)
+
+			bytes, _ := chelpers.BuildSuccessJSON(j).Encode()
+			return string(bytes)
+		})
+}
@@ -22,11 +22,14 @@ func SocketInit(so socketio.Socket) {
 	}
 
 	so.On("disconnection", func() {
-		chelpers.DeauthenticateSocket(so.Id())
 		if chelpers.IsLoggedInSocket(so.Id()) {
 			steamid := chelpers.GetSteamId(so.Id())
-			broadcaster.RemoveSocket(steamid)
+			// only leave the queue once their last tab is closed
+			if !broadcaster.RemoveSocket(steamid, so) {
+				models.DequeuePlayer(steamid)
+			}
 		}
+		chelpers.DeauthenticateSocket(so.Id())
 		helpers.Logger.Debug("on disconnect")
 	})
 
@@ -56,8 +59,6 @@ func SocketInit(so socketio.Socket) {
 	// LOBBY CREATE
 	so.On("lobbyCreate", handler.LobbyCreate(so))
 
-	so.On("serverVerify", handler.ServerVerify(so))
-
 	so.On("lobbyClose", handler.LobbyClose(so))
 
 	so.On("lobbyJoin", handler.LobbyJoin(so))
@@ -67,8 +68,12 @@ func SocketInit(so socketio.Socket) {
 	} else {
 		so.On("lobbySpectatorJoin", handler.LobbyNoLoginSpectatorJoin(so))
 	}
+	so.On("lobbyResync", handler.LobbyResync(so))
+
 	so.On("lobbyKick", handler.LobbyKick(so))
 
+	so.On("lobbySubClaim", handler.LobbySubClaim(so))
+
 	so.On("playerReady", handler.PlayerReady(so))
 
 	so.On("playerUnready", handler.PlayerUnready(so))
@@ -81,10 +86,52 @@ func SocketInit(so socketio.Socket) {
 
 	so.On("chatSend", handler.ChatSend(so))
 
+	so.On("chatHistoryGet", handler.ChatHistoryGet(so))
+
+	so.On("chatDelete", handler.ChatDelete(so))
+
+	so.On("chatMute", handler.ChatMute(so))
+
+	so.On("chatUnmute", handler.ChatUnmute(so))
+
 	so.On("adminChangeRole", handler.AdminChangeRole(so))
 
+	so.On("adminPaulingStatus", handler.AdminPaulingStatus(so))
+
+	so.On("adminGetLog", handler.AdminGetLog(so))
+
+	so.On("adminGetPermissions", handler.AdminGetPermissions(so))
+
+	so.On("adminSetPermission", handler.AdminSetPermission(so))
+
+	so.On("adminBanPlayer", handler.AdminBanPlayer(so))
+
+	so.On("adminUnbanPlayer", handler.AdminUnbanPlayer(so))
+
+	so.On("adminGetBans", handler.AdminGetBans(so))
+
+	so.On("playerBanAppeal", handler.PlayerBanAppeal(so))
+
+	so.On("adminGetAppeals", handler.AdminGetAppeals(so))
+
+	so.On("adminAppealResolve", handler.AdminAppealResolve(so))
+
+	so.On("adminServerAdd", handler.AdminServerAdd(so))
+
+	so.On("adminServerRemove", handler.AdminServerRemove(so))
+
+	so.On("adminServerSetEnabled", handler.AdminServerSetEnabled(so))
+
+	so.On("adminServerCheck", handler.AdminServerCheck(so))
+
+	so.On("adminServerList", handler.AdminServerList(so))
+
 	so.On("requestLobbyListData", handler.RequestLobbyListData(so))
 
+	so.On("queueJoin", handler.QueueJoin(so))
+
+	so.On("queueLeave", handler.QueueLeave(so))
+
 	//Debugging handlers
 	if config.Constants.ServerMockUp {
 		so.On("debugLobbyFill", handler.DebugLobbyFill(so))
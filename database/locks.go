@@ -0,0 +1,69 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package database
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/TF2Stadium/Helen/helpers"
+)
+
+// Locks records with Postgres advisory locks, so they're locked for every
+// Helen instance using the database. A lock is held by a transaction that's
+// committed when it's unlocked.
+type postgresLocker struct {
+	// waiters in this instance queue up here instead of each holding a
+	// connection while they wait
+	local helpers.RecordLocker
+
+	lock sync.Mutex
+	held map[string]*sql.Tx
+}
+
+func NewPostgresLocker() helpers.RecordLocker {
+	return &postgresLocker{
+		local: helpers.NewMemoryLocker(),
+		held:  make(map[string]*sql.Tx),
+	}
+}
+
+// Fails when the database lock can't be taken, other instances could be
+// changing the record so going ahead with just the local lock isn't safe.
+func (l *postgresLocker) Lock(key string) error {
+	l.local.Lock(key)
+
+	tx, err := DB.DB().Begin()
+	if err != nil {
+		helpers.Logger.Warning("Couldn't lock %s in the database: %s", key, err.Error())
+		l.local.Unlock(key)
+		return err
+	}
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", key); err != nil {
+		helpers.Logger.Warning("Couldn't lock %s in the database: %s", key, err.Error())
+		tx.Rollback()
+		l.local.Unlock(key)
+		return err
+	}
+
+	l.lock.Lock()
+	l.held[key] = tx
+	l.lock.Unlock()
+	return nil
+}
+
+func (l *postgresLocker) Unlock(key string) {
+	l.lock.Lock()
+	tx, ok := l.held[key]
+	delete(l.held, key)
+	l.lock.Unlock()
+
+	if ok {
+		if err := tx.Commit(); err != nil {
+			helpers.Logger.Warning("Couldn't unlock %s in the database: %s", key, err.Error())
+		}
+	}
+	l.local.Unlock(key)
+}
@@ -15,10 +15,27 @@ func Do() {
 	database.DB.AutoMigrate(&models.LobbySlot{})
 	database.DB.AutoMigrate(&models.ServerRecord{})
 	database.DB.AutoMigrate(&models.PlayerStats{})
+	database.DB.AutoMigrate(&models.PlayerStatsFormatCount{})
 	database.DB.AutoMigrate(&models.PlayerSetting{})
 	database.DB.AutoMigrate(&models.AdminLogEntry{})
 	database.DB.AutoMigrate(&models.PlayerBan{})
+	database.DB.AutoMigrate(&models.LobbyStateTransition{})
+	database.DB.AutoMigrate(&models.GameServer{})
+	database.DB.AutoMigrate(&models.Substitute{})
+	database.DB.AutoMigrate(&models.Requirement{})
+	database.DB.AutoMigrate(&models.LobbyInvite{})
+	database.DB.AutoMigrate(&models.ChatMessage{})
+	database.DB.AutoMigrate(&models.ChatMute{})
+	database.DB.AutoMigrate(&models.BanAppeal{})
+	database.DB.AutoMigrate(&models.RolePermission{})
+	database.DB.AutoMigrate(&models.LobbyVersion{})
+	database.DB.AutoMigrate(&models.QueueEntry{})
+	database.DB.AutoMigrate(&models.MatchInterval{})
 
 	database.DB.Model(&models.LobbySlot{}).AddUniqueIndex("idx_lobby_slot_lobby_id_slot", "lobby_id", "slot")
 	database.DB.Model(&models.PlayerSetting{}).AddUniqueIndex("idx_player_id_key", "player_id", "key")
+	database.DB.Model(&models.RolePermission{}).AddUniqueIndex("idx_role_permission_role_action", "role", "action")
+	database.DB.Model(&models.LobbyVersion{}).AddUniqueIndex("idx_lobby_version_lobby_id", "lobby_id")
+	database.DB.Model(&models.MatchInterval{}).AddUniqueIndex("idx_match_interval_type_league", "type", "league")
+	database.DB.Model(&models.ChatMessage{}).AddIndex("idx_chat_message_room_id", "room", "id")
 }
@@ -4,15 +4,22 @@
 
 package authority
 
-import "encoding/gob"
+import (
+	"encoding/gob"
+	"sync"
+)
 
 type AuthAction int
 
 type AuthRole int
 
+// permissions can be changed while requests are checking them
+var permissionsLock sync.RWMutex
 var permissions = make(map[AuthRole]map[AuthAction]bool)
 
 func (role AuthRole) Allow(action AuthAction) AuthRole {
+	permissionsLock.Lock()
+	defer permissionsLock.Unlock()
 	amap, ok := permissions[role]
 	if !ok {
 		amap = make(map[AuthAction]bool)
@@ -24,6 +31,8 @@ func (role AuthRole) Allow(action AuthAction) AuthRole {
 }
 
 func (role AuthRole) Disallow(action AuthAction) AuthRole {
+	permissionsLock.Lock()
+	defer permissionsLock.Unlock()
 	amap, ok := permissions[role]
 	if !ok {
 		amap = make(map[AuthAction]bool)
@@ -35,6 +44,8 @@ func (role AuthRole) Disallow(action AuthAction) AuthRole {
 }
 
 func (myrole AuthRole) Inherit(otherrole AuthRole) AuthRole {
+	permissionsLock.Lock()
+	defer permissionsLock.Unlock()
 	mymap, ok := permissions[myrole]
 	if !ok {
 		mymap = make(map[AuthAction]bool)
@@ -54,6 +65,8 @@ func (myrole AuthRole) Inherit(otherrole AuthRole) AuthRole {
 }
 
 func (role AuthRole) Can(action AuthAction) bool {
+	permissionsLock.RLock()
+	defer permissionsLock.RUnlock()
 	mymap, ok := permissions[role]
 	return ok && mymap[action]
 }
@@ -64,6 +77,8 @@ func Can(role_int int, action AuthAction) bool {
 }
 
 func Reset() {
+	permissionsLock.Lock()
+	defer permissionsLock.Unlock()
 	permissions = make(map[AuthRole]map[AuthAction]bool)
 }
 
@@ -5,6 +5,7 @@
 package authority
 
 import (
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -52,3 +53,26 @@ func TestInherit(t *testing.T) {
 	RoleAdmin.Disallow(ActionTwo)
 	assert.False(t, RoleAdmin.Can(ActionTwo))
 }
+
+func TestConcurrentChanges(t *testing.T) {
+	defer Reset()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				RoleAdmin.Can(ActionTwo)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				RoleAdmin.Allow(ActionTwo)
+				RoleAdmin.Disallow(ActionTwo)
+			}
+		}()
+	}
+	wg.Wait()
+}
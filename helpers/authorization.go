@@ -27,13 +27,31 @@ var RoleMap = map[string]authority.AuthRole{
 
 // You cant's change the order of these
 const (
-	ActionBanPlayer  authority.AuthAction = iota
-	ActionChangeRole authority.AuthAction = iota
+	ActionBanPlayer         authority.AuthAction = iota
+	ActionChangeRole        authority.AuthAction = iota
+	ActionViewPaulingStatus authority.AuthAction = iota
+	ActionManageServers     authority.AuthAction = iota
+	ActionChatMute          authority.AuthAction = iota
+	ActionChatDelete        authority.AuthAction = iota
+	ActionViewAdminLog      authority.AuthAction = iota
+	ActionCloseLobby        authority.AuthAction = iota // any lobby, not just your own
+	ActionKickFromLobby     authority.AuthAction = iota
+	ActionManagePermissions authority.AuthAction = iota
+	ActionViewStats         authority.AuthAction = iota // broadcaster queues and such
 )
 
 var ActionNames = map[authority.AuthAction]string{
-	ActionBanPlayer:  "ActionBanPlayer",
-	ActionChangeRole: "ActionChangeRole",
+	ActionBanPlayer:         "ActionBanPlayer",
+	ActionChangeRole:        "ActionChangeRole",
+	ActionViewPaulingStatus: "ActionViewPaulingStatus",
+	ActionManageServers:     "ActionManageServers",
+	ActionChatMute:          "ActionChatMute",
+	ActionChatDelete:        "ActionChatDelete",
+	ActionViewAdminLog:      "ActionViewAdminLog",
+	ActionCloseLobby:        "ActionCloseLobby",
+	ActionKickFromLobby:     "ActionKickFromLobby",
+	ActionManagePermissions: "ActionManagePermissions",
+	ActionViewStats:         "ActionViewStats",
 }
 
 func RoleExists(role authority.AuthRole) bool {
@@ -46,10 +64,30 @@ func ActionExists(role authority.AuthAction) bool {
 	return ok
 }
 
+func ActionByName(name string) (authority.AuthAction, bool) {
+	for action, actionName := range ActionNames {
+		if actionName == name {
+			return action, true
+		}
+	}
+	return 0, false
+}
+
+// The default grants. Once the database is up, the ones stored there are
+// used instead, see models/permissions.go
 func InitAuthorization() {
 	RoleMod.Inherit(RolePlayer)
 	RoleMod.Allow(ActionBanPlayer)
+	RoleMod.Allow(ActionChatMute)
+	RoleMod.Allow(ActionChatDelete)
+	RoleMod.Allow(ActionKickFromLobby)
 
 	RoleAdmin.Inherit(RoleMod)
 	RoleAdmin.Allow(ActionChangeRole)
+	RoleAdmin.Allow(ActionViewPaulingStatus)
+	RoleAdmin.Allow(ActionManageServers)
+	RoleAdmin.Allow(ActionViewAdminLog)
+	RoleAdmin.Allow(ActionCloseLobby)
+	RoleAdmin.Allow(ActionManagePermissions)
+	RoleAdmin.Allow(ActionViewStats)
 }
@@ -0,0 +1,40 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package helpers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryption(t *testing.T) {
+	key, err := ParseKey("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	assert.Nil(t, err)
+
+	encrypted, err := Encrypt(key, "rcon password")
+	assert.Nil(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "rcon password")
+
+	decrypted, err := Decrypt(key, encrypted)
+	assert.Nil(t, err)
+	assert.Equal(t, "rcon password", decrypted)
+
+	// values from before encryption was turned on
+	decrypted, err = Decrypt(key, "plaintext")
+	assert.Nil(t, err)
+	assert.Equal(t, "plaintext", decrypted)
+
+	other, _ := ParseKey("ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=")
+	_, err = Decrypt(other, encrypted)
+	assert.NotNil(t, err)
+
+	_, err = Decrypt(nil, encrypted)
+	assert.Equal(t, ErrNoKey, err)
+
+	_, err = ParseKey("c2hvcnQ=")
+	assert.NotNil(t, err)
+}
@@ -4,11 +4,17 @@
 
 package helpers
 
-import "github.com/bitly/go-simplejson"
+import (
+	"math"
+	"time"
+
+	"github.com/bitly/go-simplejson"
+)
 
 type TPError struct {
-	Str  string
-	Code int
+	Str        string
+	Code       int
+	RetryAfter time.Duration // how long to wait before trying again, if it helps at all
 }
 
 func (e *TPError) Error() string {
@@ -21,6 +27,15 @@ func NewTPError(str string, code int) *TPError {
 		Code: code}
 }
 
+// An error for a request that was made too soon, which can be retried
+// after the given time
+func NewRetryTPError(str string, code int, retryAfter time.Duration) *TPError {
+	return &TPError{
+		Str:        str,
+		Code:       code,
+		RetryAfter: retryAfter}
+}
+
 func NewTPErrorFromError(e error) *TPError {
 	if e == nil {
 		return nil
@@ -37,6 +52,10 @@ func (e *TPError) ErrorJSON() *simplejson.Json {
 	j.Set("success", false)
 	j.Set("message", e.Str)
 	j.Set("code", e.Code)
+	if e.RetryAfter > 0 {
+		// whole seconds, rounded up so retrying right at it works
+		j.Set("retryAfter", int(math.Ceil(e.RetryAfter.Seconds())))
+	}
 
 	return j
 }
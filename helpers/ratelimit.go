@@ -0,0 +1,104 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package helpers
+
+import (
+	"sync"
+	"time"
+)
+
+// How fast a token bucket refills, and how many tokens it holds
+type Rate struct {
+	PerMinute int
+	Burst     int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// the rate it was last taken from, buckets of different rates share a
+	// limiter
+	perSecond float64
+	burst     float64
+}
+
+// Token buckets by key. The rate is passed on each call so it can come
+// straight from config.
+type RateLimiter struct {
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// replaced in tests
+var now = time.Now
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: now(),
+	}
+}
+
+// Takes a token from key's bucket. If it's empty, returns false and how long
+// until the next token. A rate with PerMinute 0 doesn't limit anything.
+func (l *RateLimiter) Take(key string, rate Rate) (bool, time.Duration) {
+	if rate.PerMinute <= 0 {
+		return true, 0
+	}
+	burst := float64(rate.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	perSecond := float64(rate.PerMinute) / 60
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	t := now()
+	l.sweep(t)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: t}
+		l.buckets[key] = b
+	}
+
+	b.tokens += t.Sub(b.last).Seconds() * perSecond
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = t
+	b.perSecond = perSecond
+	b.burst = burst
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Forgets key's bucket, so it starts out full again
+func (l *RateLimiter) Reset(key string) {
+	l.lock.Lock()
+	delete(l.buckets, key)
+	l.lock.Unlock()
+}
+
+// drops buckets that have refilled, once a minute
+func (l *RateLimiter) sweep(t time.Time) {
+	if t.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = t
+
+	for key, b := range l.buckets {
+		if b.tokens+t.Sub(b.last).Seconds()*b.perSecond >= b.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
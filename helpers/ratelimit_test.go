@@ -0,0 +1,69 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package helpers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	limiter := NewRateLimiter()
+	rate := Rate{PerMinute: 6, Burst: 2}
+
+	ok, _ := limiter.Take("a", rate)
+	assert.True(t, ok)
+	ok, _ = limiter.Take("a", rate)
+	assert.True(t, ok)
+	ok, wait := limiter.Take("a", rate)
+	assert.False(t, ok)
+	assert.Equal(t, 10*time.Second, wait)
+
+	// other keys have their own bucket
+	ok, _ = limiter.Take("b", rate)
+	assert.True(t, ok)
+
+	current = current.Add(5 * time.Second)
+	ok, wait = limiter.Take("a", rate)
+	assert.False(t, ok)
+	assert.Equal(t, 5*time.Second, wait)
+
+	current = current.Add(5 * time.Second)
+	ok, _ = limiter.Take("a", rate)
+	assert.True(t, ok)
+
+	limiter.Reset("a")
+	ok, _ = limiter.Take("a", rate)
+	assert.True(t, ok)
+
+	ok, _ = limiter.Take("a", Rate{})
+	assert.True(t, ok)
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	current := time.Now()
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	limiter := NewRateLimiter()
+	slow := Rate{PerMinute: 1, Burst: 2}
+	limiter.Take("slow", slow)
+	limiter.Take("slow", slow)
+
+	// a sweep started by a faster rate doesn't refill the slow bucket
+	current = current.Add(61 * time.Second)
+	limiter.Take("fast", Rate{PerMinute: 600, Burst: 10})
+
+	ok, _ := limiter.Take("slow", slow)
+	assert.True(t, ok)
+	ok, _ = limiter.Take("slow", slow)
+	assert.False(t, ok)
+}
@@ -5,43 +5,78 @@
 package helpers
 
 import (
+	"fmt"
 	"reflect"
 	"sync"
 )
 
-type record struct {
-	id      uint
-	recType reflect.Type
+// Serializes changes to records. The default one only works within a single
+// Helen instance, database.NewPostgresLocker works across all of them.
+// Lock returns an error when the record couldn't be locked, it isn't locked
+// then and mustn't be unlocked.
+type RecordLocker interface {
+	Lock(key string) error
+	Unlock(key string)
 }
 
-var mutexStore = make(map[record]*sync.Mutex)
-var storeLock = &sync.Mutex{}
+var recordLocker RecordLocker = NewMemoryLocker()
 
-func LockRecord(id uint, recType interface{}) {
-	storeLock.Lock()
-	key := record{id, reflect.TypeOf(recType)}
-	mutex, e := mutexStore[key]
-	if !e {
-		mutex = &sync.Mutex{}
-		mutexStore[key] = mutex
-	}
-	storeLock.Unlock()
-	mutex.Lock()
+// Has to be called before anything is locked
+func SetRecordLocker(locker RecordLocker) {
+	recordLocker = locker
+}
+
+func recordKey(id uint, recType interface{}) string {
+	return fmt.Sprintf("%s_%d", reflect.TypeOf(recType), id)
+}
+
+func LockRecord(id uint, recType interface{}) error {
+	return recordLocker.Lock(recordKey(id, recType))
 }
 
 func UnlockRecord(id uint, recType interface{}) {
-	storeLock.Lock()
-	defer storeLock.Unlock()
-	key := record{id, reflect.TypeOf(recType)}
-	mutex, e := mutexStore[key]
-	if e {
-		mutex.Unlock()
+	recordLocker.Unlock(recordKey(id, recType))
+}
+
+// A mutex along with how many goroutines hold or wait for it, so it can be
+// forgotten once nobody does
+type recordMutex struct {
+	sync.Mutex
+	refs int
+}
+
+type memoryLocker struct {
+	storeLock  sync.Mutex
+	mutexStore map[string]*recordMutex
+}
+
+func NewMemoryLocker() RecordLocker {
+	return &memoryLocker{mutexStore: make(map[string]*recordMutex)}
+}
+
+func (l *memoryLocker) Lock(key string) error {
+	l.storeLock.Lock()
+	mutex, e := l.mutexStore[key]
+	if !e {
+		mutex = &recordMutex{}
+		l.mutexStore[key] = mutex
 	}
+	mutex.refs++
+	l.storeLock.Unlock()
+	mutex.Lock()
+	return nil
 }
 
-func RemoveRecord(id uint, recType interface{}) {
-	storeLock.Lock()
-	defer storeLock.Unlock()
-	key := record{id, reflect.TypeOf(recType)}
-	delete(mutexStore, key)
+func (l *memoryLocker) Unlock(key string) {
+	l.storeLock.Lock()
+	defer l.storeLock.Unlock()
+	mutex, e := l.mutexStore[key]
+	if !e {
+		return
+	}
+	mutex.refs--
+	if mutex.refs == 0 {
+		delete(l.mutexStore, key)
+	}
+	mutex.Unlock()
 }
@@ -0,0 +1,75 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package helpers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRecord struct{}
+
+func TestLockRecord(t *testing.T) {
+	LockRecord(1, &testRecord{})
+	// other records aren't affected
+	LockRecord(2, &testRecord{})
+	UnlockRecord(2, &testRecord{})
+
+	locked := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		LockRecord(1, &testRecord{})
+		locked <- true
+		UnlockRecord(1, &testRecord{})
+		close(done)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("record was locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	UnlockRecord(1, &testRecord{})
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("record wasn't unlocked")
+	}
+
+	// mutexes are dropped once nobody holds them
+	<-done
+	locker := recordLocker.(*memoryLocker)
+	locker.storeLock.Lock()
+	defer locker.storeLock.Unlock()
+	assert.Empty(t, locker.mutexStore)
+}
+
+type fakeLocker struct {
+	keys []string
+	err  error
+}
+
+func (l *fakeLocker) Lock(key string) error {
+	l.keys = append(l.keys, key)
+	return l.err
+}
+
+func (l *fakeLocker) Unlock(key string) {}
+
+func TestSetRecordLocker(t *testing.T) {
+	locker := &fakeLocker{}
+	SetRecordLocker(locker)
+	defer SetRecordLocker(NewMemoryLocker())
+
+	assert.Nil(t, LockRecord(3, &testRecord{}))
+	assert.Equal(t, []string{"*helpers.testRecord_3"}, locker.keys)
+
+	locker.err = errors.New("connection refused")
+	assert.Equal(t, locker.err, LockRecord(4, &testRecord{}))
+}
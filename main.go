@@ -14,6 +14,7 @@ import (
 	"github.com/TF2Stadium/Helen/config/stores"
 	"github.com/TF2Stadium/Helen/controllers/broadcaster"
 	chelpers "github.com/TF2Stadium/Helen/controllers/controllerhelpers"
+	"github.com/TF2Stadium/Helen/controllers/events"
 	"github.com/TF2Stadium/Helen/database"
 	"github.com/TF2Stadium/Helen/database/migrations"
 	"github.com/TF2Stadium/Helen/helpers"
@@ -31,11 +32,18 @@ func main() {
 	config.SetupConstants()
 	database.Init()
 	migrations.Do()
+	if config.Constants.LockBackend == "postgres" {
+		helpers.SetRecordLocker(database.NewPostgresLocker())
+	}
+	if err := models.LoadPermissions(); err != nil {
+		helpers.Logger.Critical("Couldn't load permissions, using the defaults: %s", err.Error())
+	}
 	stores.SetupStores()
 	models.PaulingConnect()
-	go models.ReadyTimeoutListener()
-	StartListener()
+	models.RearmReadyUpTimers()
+	events.StartListener()
 	chelpers.StartGlobalLogger()
+	chelpers.StartMatchmaker()
 	// lobby := models.NewLobby("cp_badlands", 10, "a", "a", 1)
 	helpers.Logger.Debug("Starting the server")
 
@@ -47,6 +55,22 @@ func main() {
 	if err != nil {
 		helpers.Logger.Fatal(err.Error())
 	}
+	if config.Constants.PubSubBackend == "postgres" {
+		backend, err := broadcaster.NewPostgresBackend(database.DbUrl, database.DB.DB())
+		if err != nil {
+			helpers.Logger.Fatal(err.Error())
+		}
+		broadcaster.SetBackend(backend)
+	}
+	overflow, ok := broadcaster.OverflowPolicies[config.Constants.BroadcastOverflow]
+	if !ok {
+		helpers.Logger.Warning("Unknown broadcast overflow policy %s, using drop-oldest", config.Constants.BroadcastOverflow)
+	}
+	broadcaster.SetQueueOptions(broadcaster.QueueOptions{
+		Size:     config.Constants.BroadcastQueueSize,
+		Overflow: overflow,
+		Coalesce: config.Constants.BroadcastCoalesce,
+	})
 	broadcaster.Init(socketServer)
 	defer broadcaster.Stop()
 	routes.SetupSocketRoutes(socketServer)
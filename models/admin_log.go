@@ -5,9 +5,16 @@
 package models
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
 	"github.com/TF2Stadium/Helen/database"
 	"github.com/TF2Stadium/Helen/helpers"
 	"github.com/TF2Stadium/Helen/helpers/authority"
+	"github.com/bitly/go-simplejson"
 	"github.com/jinzhu/gorm"
 )
 
@@ -17,18 +24,188 @@ type AdminLogEntry struct {
 	Player   Player
 	RelID    uint   `sql:"default:0"`
 	RelText  string `sql:"default:''"`
+
+	// JSON of what was changed, before and after the action
+	Before string `sql:"size:65535;default:''"`
+	After  string `sql:"size:65535;default:''"`
 }
 
+// Most entries a single query returns
+const AdminLogMaxPageSize = 100
+
 func LogCustomAdminAction(playerid uint, reltext string, relid uint) error {
+	return LogCustomAdminChange(playerid, reltext, relid, nil, nil)
+}
+
+func LogAdminAction(playerid uint, permission authority.AuthAction, relid uint) error {
+	return LogCustomAdminAction(playerid, helpers.ActionNames[permission], relid)
+}
+
+// Logs an action along with what it changed. before and after are stored as
+// JSON, nil leaves them empty.
+func LogCustomAdminChange(playerid uint, reltext string, relid uint, before interface{}, after interface{}) error {
 	entry := AdminLogEntry{
 		PlayerID: playerid,
 		RelID:    relid,
 		RelText:  reltext,
+		Before:   encodeAdminLogValue(before),
+		After:    encodeAdminLogValue(after),
 	}
 
 	return database.DB.Create(&entry).Error
 }
 
-func LogAdminAction(playerid uint, permission authority.AuthAction, relid uint) error {
-	return LogCustomAdminAction(playerid, helpers.ActionNames[permission], relid)
+func LogAdminChange(playerid uint, permission authority.AuthAction, relid uint, before interface{}, after interface{}) error {
+	return LogCustomAdminChange(playerid, helpers.ActionNames[permission], relid, before, after)
+}
+
+func encodeAdminLogValue(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		helpers.Logger.Warning("Couldn't encode admin log value: %s", err.Error())
+		return ""
+	}
+	return string(bytes)
+}
+
+// Actions whose RelID is a player, for the others it's a server or nothing
+var playerRelActions = []string{
+	helpers.ActionNames[helpers.ActionBanPlayer],
+	"ActionUnbanPlayer",
+	helpers.ActionNames[helpers.ActionChangeRole],
+	helpers.ActionNames[helpers.ActionChatMute],
+	helpers.ActionNames[helpers.ActionChatDelete],
+	"ActionAcceptAppeal",
+	"ActionRejectAppeal",
+}
+
+// Filters for GetAdminLog, zero values match everything
+type AdminLogQuery struct {
+	ActorID uint // player that did it
+	// player it was done to, only matches actions done to players
+	TargetID uint
+	Action   string // like "ActionBanPlayer"
+	Since    time.Time
+	Until    time.Time
+
+	Offset   int
+	Limit    int  // AdminLogMaxPageSize if 0 or larger
+	BeforeID uint // only entries older than this one, pages don't shift when new ones are logged
+}
+
+// Entries matching the query, newest first
+func GetAdminLog(query AdminLogQuery) ([]*AdminLogEntry, error) {
+	db := database.DB.Preload("Player").Order("id desc")
+
+	if query.ActorID != 0 {
+		db = db.Where("player_id = ?", query.ActorID)
+	}
+	if query.TargetID != 0 {
+		db = db.Where("rel_id = ? AND rel_text IN (?)", query.TargetID, playerRelActions)
+	}
+	if query.Action != "" {
+		db = db.Where("rel_text = ?", query.Action)
+	}
+	if !query.Since.IsZero() {
+		db = db.Where("created_at >= ?", query.Since)
+	}
+	if !query.Until.IsZero() {
+		db = db.Where("created_at < ?", query.Until)
+	}
+	if query.BeforeID != 0 {
+		db = db.Where("id < ?", query.BeforeID)
+	}
+
+	limit := query.Limit
+	if limit <= 0 || limit > AdminLogMaxPageSize {
+		limit = AdminLogMaxPageSize
+	}
+
+	var entries []*AdminLogEntry
+	err := db.Offset(query.Offset).Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// Every entry matching the query, for exporting. Offset and Limit are
+// ignored. Pages are read by ID, so entries logged during the export don't
+// shift them.
+func GetAllAdminLog(query AdminLogQuery) ([]*AdminLogEntry, error) {
+	var all []*AdminLogEntry
+	query.Offset = 0
+	query.Limit = AdminLogMaxPageSize
+	for {
+		entries, err := GetAdminLog(query)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, entries...)
+		if len(entries) < AdminLogMaxPageSize {
+			return all, nil
+		}
+		query.BeforeID = entries[len(entries)-1].ID
+	}
+}
+
+func decodeAdminLogValue(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return value
+	}
+	return decoded
+}
+
+func DecorateAdminLogEntryJSON(entry *AdminLogEntry) *simplejson.Json {
+	j := simplejson.New()
+	j.Set("id", entry.ID)
+	j.Set("createdAt", entry.CreatedAt.Unix())
+	j.Set("action", entry.RelText)
+	j.Set("relId", entry.RelID)
+	j.Set("before", decodeAdminLogValue(entry.Before))
+	j.Set("after", decodeAdminLogValue(entry.After))
+
+	actor := simplejson.New()
+	actor.Set("id", entry.PlayerID)
+	actor.Set("steamid", entry.Player.SteamId)
+	actor.Set("name", entry.Player.Name)
+	j.Set("actor", actor)
+	return j
+}
+
+func DecorateAdminLogJSON(entries []*AdminLogEntry) *simplejson.Json {
+	list := make([]*simplejson.Json, len(entries))
+	for i, entry := range entries {
+		list[i] = DecorateAdminLogEntryJSON(entry)
+	}
+	j := simplejson.New()
+	j.Set("entries", list)
+	return j
+}
+
+// Writes the entries as CSV, with a header row
+func WriteAdminLogCSV(w io.Writer, entries []*AdminLogEntry) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "created_at", "actor_steamid", "actor_name",
+		"action", "rel_id", "before", "after"})
+
+	for _, entry := range entries {
+		writer.Write([]string{
+			strconv.FormatUint(uint64(entry.ID), 10),
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			entry.Player.SteamId,
+			entry.Player.Name,
+			entry.RelText,
+			strconv.FormatUint(uint64(entry.RelID), 10),
+			entry.Before,
+			entry.After,
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
 }
@@ -5,7 +5,10 @@
 package models_test
 
 import (
+	"bytes"
+	"encoding/csv"
 	"testing"
+	"time"
 
 	"github.com/TF2Stadium/Helen/database"
 	"github.com/TF2Stadium/Helen/helpers"
@@ -32,3 +35,69 @@ func TestLogCreation(t *testing.T) {
 	database.DB.Model(obj).Count(&count)
 	assert.Equal(t, 2, count)
 }
+
+func TestAdminLogQuery(t *testing.T) {
+	testhelpers.CleanupDB()
+	admin := testhelpers.CreatePlayer()
+	mod := testhelpers.CreatePlayer()
+	target := testhelpers.CreatePlayer()
+
+	models.LogAdminChange(admin.ID, helpers.ActionChangeRole, target.ID,
+		map[string]string{"role": "player"}, map[string]string{"role": "moderator"})
+	models.LogAdminAction(mod.ID, helpers.ActionBanPlayer, target.ID)
+	models.LogAdminChange(mod.ID, helpers.ActionChatDelete, target.ID,
+		map[string]interface{}{"id": 42}, nil)
+
+	entries, err := models.GetAdminLog(models.AdminLogQuery{})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(entries))
+	// newest first
+	assert.Equal(t, "ActionChatDelete", entries[0].RelText)
+
+	entries, _ = models.GetAdminLog(models.AdminLogQuery{ActorID: mod.ID})
+	assert.Equal(t, 2, len(entries))
+	entries, _ = models.GetAdminLog(models.AdminLogQuery{TargetID: target.ID, Action: "ActionChangeRole"})
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, admin.SteamId, entries[0].Player.SteamId)
+	assert.Equal(t, `{"role":"player"}`, entries[0].Before)
+	assert.Equal(t, `{"role":"moderator"}`, entries[0].After)
+
+	entries, _ = models.GetAdminLog(models.AdminLogQuery{Since: time.Now().Add(time.Hour)})
+	assert.Equal(t, 0, len(entries))
+
+	entries, _ = models.GetAdminLog(models.AdminLogQuery{Offset: 1, Limit: 1})
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "ActionBanPlayer", entries[0].RelText)
+
+	newest, _ := models.GetAdminLog(models.AdminLogQuery{Limit: 1})
+	entries, _ = models.GetAdminLog(models.AdminLogQuery{BeforeID: newest[0].ID})
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "ActionBanPlayer", entries[0].RelText)
+
+	all, err := models.GetAllAdminLog(models.AdminLogQuery{})
+	assert.Nil(t, err)
+	buf := &bytes.Buffer{}
+	assert.Nil(t, models.WriteAdminLogCSV(buf, all))
+	records, err := csv.NewReader(buf).ReadAll()
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(records))
+	assert.Equal(t, "action", records[0][4])
+	assert.Equal(t, `{"role":"moderator"}`, records[3][7])
+}
+
+func TestAdminLogTargetFilter(t *testing.T) {
+	testhelpers.CleanupDB()
+	mod := testhelpers.CreatePlayer()
+	target := testhelpers.CreatePlayer()
+
+	models.LogAdminAction(mod.ID, helpers.ActionBanPlayer, target.ID)
+	models.LogAdminAction(mod.ID, helpers.ActionChatDelete, target.ID)
+	// a server with the same ID as the player
+	models.LogAdminAction(mod.ID, helpers.ActionManageServers, target.ID)
+
+	entries, err := models.GetAdminLog(models.AdminLogQuery{TargetID: target.ID})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "ActionChatDelete", entries[0].RelText)
+	assert.Equal(t, "ActionBanPlayer", entries[1].RelText)
+}
@@ -0,0 +1,174 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/bitly/go-simplejson"
+	"github.com/jinzhu/gorm"
+)
+
+type AppealState int
+
+const (
+	AppealOpen AppealState = iota
+	AppealAccepted
+	AppealRejected
+)
+
+var AppealStateNames = map[AppealState]string{
+	AppealOpen:     "open",
+	AppealAccepted: "accepted",
+	AppealRejected: "rejected",
+}
+
+// A banned player asking for a ban to be lifted
+type BanAppeal struct {
+	gorm.Model
+	BanID    uint
+	Ban      PlayerBan
+	PlayerID uint
+	Player   Player
+	Message  string `sql:"size:2048"`
+
+	State        AppealState `sql:"default:0"`
+	ReviewedByID uint        `sql:"default:0"`
+	Response     string      `sql:"size:2048"`
+}
+
+// Appeals one of the player's active bans. Each ban can only have one open
+// appeal at a time.
+func (player *Player) AppealBan(banID uint, message string) (*BanAppeal, *helpers.TPError) {
+	ban := &PlayerBan{}
+	err := db.DB.Where("id = ? AND player_id = ? AND active = TRUE AND until > now()", banID, player.ID).
+		First(ban).Error
+	if err != nil {
+		return nil, helpers.NewTPError("You don't have that ban.", -1)
+	}
+	if message == "" {
+		return nil, helpers.NewTPError("Please say why the ban should be lifted.", -1)
+	}
+
+	count := 0
+	db.DB.Model(&BanAppeal{}).Where("ban_id = ? AND state = ?", ban.ID, AppealOpen).Count(&count)
+	if count != 0 {
+		return nil, helpers.NewTPError("You've already appealed this ban.", -1)
+	}
+
+	appeal := &BanAppeal{
+		BanID:    ban.ID,
+		Ban:      *ban,
+		PlayerID: player.ID,
+		Message:  message,
+	}
+	if err := db.DB.Create(appeal).Error; err != nil {
+		return nil, helpers.NewTPErrorFromError(err)
+	}
+	return appeal, nil
+}
+
+func GetBanAppealById(id uint) (*BanAppeal, *helpers.TPError) {
+	appeal := &BanAppeal{}
+	if err := db.DB.Preload("Ban").Preload("Player").First(appeal, id).Error; err != nil {
+		return nil, helpers.NewTPError("Appeal not found", -1)
+	}
+	return appeal, nil
+}
+
+// Appeals waiting for a moderator, oldest first
+func GetOpenAppeals() ([]*BanAppeal, error) {
+	var appeals []*BanAppeal
+	err := db.DB.Preload("Ban").Preload("Player").Where("state = ?", AppealOpen).
+		Order("id").Find(&appeals).Error
+	return appeals, err
+}
+
+// Lifts the appealed ban
+func (appeal *BanAppeal) Accept(mod *Player, response string) *helpers.TPError {
+	tperr := appeal.resolve(AppealAccepted, mod, response, func(tx *gorm.DB) error {
+		return tx.Model(&PlayerBan{}).Where("id = ?", appeal.BanID).Update("active", false).Error
+	})
+	if tperr != nil {
+		return tperr
+	}
+
+	appeal.Ban.Active = false
+	player := &Player{}
+	if err := db.DB.First(player, appeal.PlayerID).Error; err == nil {
+		player.updateBanColumns()
+	}
+	return nil
+}
+
+func (appeal *BanAppeal) Reject(mod *Player, response string) *helpers.TPError {
+	return appeal.resolve(AppealRejected, mod, response, nil)
+}
+
+// Closes the appeal, and runs also in the same transaction. Only one
+// moderator gets to resolve it, the update doesn't match once someone else
+// has.
+func (appeal *BanAppeal) resolve(state AppealState, mod *Player, response string, also func(tx *gorm.DB) error) *helpers.TPError {
+	if appeal.State != AppealOpen {
+		return helpers.NewTPError("The appeal has already been "+AppealStateNames[appeal.State]+".", -1)
+	}
+
+	tx := db.DB.Begin()
+	if tx.Error != nil {
+		return helpers.NewTPErrorFromError(tx.Error)
+	}
+
+	update := tx.Model(&BanAppeal{}).Where("id = ? AND state = ?", appeal.ID, AppealOpen).Updates(map[string]interface{}{
+		"state":          state,
+		"reviewed_by_id": mod.ID,
+		"response":       response,
+	})
+	if update.Error != nil {
+		tx.Rollback()
+		return helpers.NewTPErrorFromError(update.Error)
+	}
+	if update.RowsAffected == 0 {
+		tx.Rollback()
+		return helpers.NewTPError("The appeal has already been reviewed.", -1)
+	}
+
+	if also != nil {
+		if err := also(tx); err != nil {
+			tx.Rollback()
+			return helpers.NewTPErrorFromError(err)
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return helpers.NewTPErrorFromError(err)
+	}
+
+	appeal.State = state
+	appeal.ReviewedByID = mod.ID
+	appeal.Response = response
+	return nil
+}
+
+func DecorateBanAppealJSON(appeal *BanAppeal) *simplejson.Json {
+	j := simplejson.New()
+	j.Set("id", appeal.ID)
+	j.Set("ban", DecoratePlayerBanJSON(&appeal.Ban))
+	j.Set("steamid", appeal.Player.SteamId)
+	j.Set("name", appeal.Player.Name)
+	j.Set("message", appeal.Message)
+	j.Set("state", AppealStateNames[appeal.State])
+	j.Set("response", appeal.Response)
+	j.Set("createdAt", appeal.CreatedAt.Unix())
+	return j
+}
+
+func DecorateBanAppealListJSON(appeals []*BanAppeal) *simplejson.Json {
+	list := make([]*simplejson.Json, len(appeals))
+	for i, appeal := range appeals {
+		list[i] = DecorateBanAppealJSON(appeal)
+	}
+	j := simplejson.New()
+	j.Set("appeals", list)
+	return j
+}
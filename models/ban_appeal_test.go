@@ -0,0 +1,61 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBanAppeal(t *testing.T) {
+	testhelpers.CleanupDB()
+	player := testhelpers.CreatePlayer()
+	mod := testhelpers.CreatePlayer()
+
+	ban, _ := player.Ban(models.PlayerBanJoin, time.Now().Add(time.Hour), "griefing", mod)
+
+	_, tperr := mod.AppealBan(ban.ID, "not my ban")
+	assert.NotNil(t, tperr)
+	_, tperr = player.AppealBan(ban.ID, "")
+	assert.NotNil(t, tperr)
+
+	appeal, tperr := player.AppealBan(ban.ID, "sorry")
+	assert.Nil(t, tperr)
+	_, tperr = player.AppealBan(ban.ID, "sorry again")
+	assert.NotNil(t, tperr)
+
+	appeals, err := models.GetOpenAppeals()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(appeals))
+	assert.Equal(t, player.SteamId, appeals[0].Player.SteamId)
+	assert.Equal(t, "griefing", appeals[0].Ban.Reason)
+
+	appeal, _ = models.GetBanAppealById(appeal.ID)
+	assert.Nil(t, appeal.Accept(mod, "ok"))
+	assert.False(t, player.IsBanned(models.PlayerBanJoin))
+	assert.NotNil(t, appeal.Reject(mod, "changed my mind"))
+	// a stale copy loaded before it was accepted
+	stale, _ := models.GetBanAppealById(appeal.ID)
+	stale.State = models.AppealOpen
+	assert.NotNil(t, stale.Reject(mod, "changed my mind"))
+	stale, _ = models.GetBanAppealById(appeal.ID)
+	assert.Equal(t, models.AppealAccepted, stale.State)
+
+	appeals, _ = models.GetOpenAppeals()
+	assert.Equal(t, 0, len(appeals))
+
+	// rejected appeals leave the ban alone
+	ban, _ = player.Ban(models.PlayerBanChat, time.Now().Add(time.Hour), "spam", mod)
+	appeal, _ = player.AppealBan(ban.ID, "sorry")
+	assert.Nil(t, appeal.Reject(mod, "no"))
+	assert.True(t, player.IsBanned(models.PlayerBanChat))
+
+	bans, _ := player.GetBans()
+	assert.Equal(t, 2, len(bans))
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/bitly/go-simplejson"
+)
+
+// How many messages a client gets when it joins a room, and at most per page
+const (
+	ChatScrollbackSize = 20
+	ChatMaxPageSize    = 50
+)
+
+type ChatMessage struct {
+	ID        uint
+	CreatedAt time.Time
+	Room      int // 0 is the global room, otherwise the lobby's ID
+	PlayerID  uint
+	Player    Player
+	Message   string `sql:"size:1024"`
+	Deleted   bool   `sql:"default:false"` // by a moderator
+}
+
+// Player is left empty so saving the message doesn't save the player too,
+// set it before decorating the message
+func NewChatMessage(message string, room int, player *Player) *ChatMessage {
+	return &ChatMessage{
+		Room:     room,
+		PlayerID: player.ID,
+		Message:  message,
+	}
+}
+
+func (message *ChatMessage) Save() error {
+	return db.DB.Save(message).Error
+}
+
+// Up to limit messages sent in room before the message with ID before,
+// oldest first. before = 0 gets the latest messages.
+func GetRoomMessages(room int, before uint, limit int) ([]*ChatMessage, error) {
+	var messages []*ChatMessage
+	query := db.DB.Preload("Player").Where("room = ? AND deleted = ?", room, false)
+	if before != 0 {
+		query = query.Where("id < ?", before)
+	}
+
+	err := query.Order("id desc").Limit(limit).Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+func DecorateChatMessageJSON(message *ChatMessage) *simplejson.Json {
+	j := simplejson.New()
+	j.Set("id", message.ID)
+	j.Set("timestamp", message.CreatedAt.Unix())
+	j.Set("message", message.Message)
+	j.Set("room", message.Room)
+	j.Set("player", DecoratePlayerSummaryJson(&message.Player))
+	return j
+}
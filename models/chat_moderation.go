@@ -0,0 +1,85 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/jinzhu/gorm"
+)
+
+// Returned by CanChat
+const (
+	ErrorCodeChatBanned = 12
+	ErrorCodeChatMuted  = 13
+)
+
+// Keeps a player from talking in one room until Until
+type ChatMute struct {
+	gorm.Model
+	PlayerID  uint
+	Room      int
+	Until     time.Time
+	Reason    string
+	MutedByID uint
+}
+
+func (player *Player) MuteInRoom(room int, until time.Time, reason string, mutedBy *Player) error {
+	mute := &ChatMute{
+		PlayerID:  player.ID,
+		Room:      room,
+		Until:     until,
+		Reason:    reason,
+		MutedByID: mutedBy.ID,
+	}
+	return db.DB.Create(mute).Error
+}
+
+// Lifts every mute the player has in the room
+func (player *Player) UnmuteInRoom(room int) error {
+	return db.DB.Where("player_id = ? AND room = ? AND until > now()", player.ID, room).
+		Delete(&ChatMute{}).Error
+}
+
+// The latest mute the player has in the room, if any
+func (player *Player) GetRoomMute(room int) (*ChatMute, bool) {
+	mute := &ChatMute{}
+	err := db.DB.Where("player_id = ? AND room = ? AND until > now()", player.ID, room).
+		Order("until desc").First(mute).Error
+	if err != nil {
+		return nil, false
+	}
+	return mute, true
+}
+
+// Checks chat bans and room mutes
+func (player *Player) CanChat(room int) *helpers.TPError {
+	if player.IsBanned(PlayerBanChat) || player.IsBanned(PlayerBanFull) {
+		return helpers.NewTPError("You've been banned from chatting.", ErrorCodeChatBanned)
+	}
+
+	if mute, muted := player.GetRoomMute(room); muted {
+		return helpers.NewTPError(fmt.Sprintf("You've been muted in this room until %s.",
+			mute.Until.UTC().Format(time.RFC1123)), ErrorCodeChatMuted)
+	}
+	return nil
+}
+
+func GetChatMessageById(id uint) (*ChatMessage, *helpers.TPError) {
+	message := &ChatMessage{}
+	if err := db.DB.First(message, id).Error; err != nil {
+		return nil, helpers.NewTPError("Message not found", -1)
+	}
+	return message, nil
+}
+
+// Hides the message from the room's history
+func (message *ChatMessage) Delete() error {
+	message.Deleted = true
+	return db.DB.Model(message).Update("deleted", true).Error
+}
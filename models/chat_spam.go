@@ -0,0 +1,124 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/helpers"
+)
+
+// Returned by CheckChatSpam when the player is sending messages too fast
+const ErrorCodeChatRateLimited = 14
+
+// strikes older than this are forgotten
+const chatStrikeWindow = 5 * time.Minute
+
+var chatLimiter = helpers.NewRateLimiter()
+
+// what a player has recently sent in a room
+type chatSpamState struct {
+	last       string
+	lastSent   time.Time
+	repeats    int
+	strikes    int
+	lastStrike time.Time
+}
+
+var (
+	chatSpamLock   = &sync.Mutex{}
+	chatSpamStates = make(map[string]*chatSpamState)
+	lastChatSweep  = time.Now()
+)
+
+// drops the state of players that haven't sent anything or been given a
+// strike in a while, once a minute. Has to be called with chatSpamLock held.
+func sweepChatSpamStates(now time.Time) {
+	if now.Sub(lastChatSweep) < time.Minute {
+		return
+	}
+	lastChatSweep = now
+
+	for key, state := range chatSpamStates {
+		if now.Sub(state.lastSent) > chatStrikeWindow && now.Sub(state.lastStrike) > chatStrikeWindow {
+			delete(chatSpamStates, key)
+		}
+	}
+}
+
+func chatSpamKey(player *Player, room int) string {
+	return fmt.Sprintf("%d_%d", player.ID, room)
+}
+
+// Checks that player isn't sending messages too fast or repeating the same
+// one over and over. Every rejected message counts as a strike, and enough
+// strikes get the player a short chat ban.
+func (player *Player) CheckChatSpam(room int, message string) *helpers.TPError {
+	key := chatSpamKey(player, room)
+	normalized := strings.ToLower(strings.TrimSpace(message))
+
+	rate := helpers.Rate{
+		PerMinute: config.Constants.ChatMessagesPerMinute,
+		Burst:     config.Constants.ChatBurst,
+	}
+	allowed, retryAfter := chatLimiter.Take(key, rate)
+
+	chatSpamLock.Lock()
+	defer chatSpamLock.Unlock()
+
+	now := time.Now()
+	sweepChatSpamStates(now)
+
+	state, ok := chatSpamStates[key]
+	if !ok {
+		state = &chatSpamState{}
+		chatSpamStates[key] = state
+	}
+	state.lastSent = now
+
+	if normalized == state.last {
+		state.repeats++
+	} else {
+		state.last = normalized
+		state.repeats = 1
+	}
+
+	var tperr *helpers.TPError
+	if !allowed {
+		tperr = helpers.NewRetryTPError("You're sending messages too fast.",
+			ErrorCodeChatRateLimited, retryAfter)
+	} else if max := config.Constants.ChatMaxRepeats; max > 0 && state.repeats > max {
+		tperr = helpers.NewTPError("Please don't repeat the same message.", ErrorCodeChatRateLimited)
+	}
+	if tperr == nil {
+		return nil
+	}
+
+	if now.Sub(state.lastStrike) > chatStrikeWindow {
+		state.strikes = 0
+	}
+	state.strikes++
+	state.lastStrike = now
+
+	if max := config.Constants.ChatSpamStrikes; max > 0 && state.strikes >= max {
+		duration := time.Duration(config.Constants.ChatSpamBanSeconds) * time.Second
+		if err := player.BanUntil(now.Add(duration), PlayerBanChat, "Spamming chat (automatic)"); err != nil {
+			helpers.Logger.Warning("Couldn't ban %s for spamming: %s", player.SteamId, err.Error())
+			return tperr
+		}
+		helpers.Logger.Info("Banned %s from chat for %s for spamming", player.SteamId, duration)
+
+		delete(chatSpamStates, key)
+		chatLimiter.Reset(key)
+		return helpers.NewRetryTPError("You've been banned from chatting for spamming.",
+			ErrorCodeChatBanned, duration)
+	}
+
+	return tperr
+}
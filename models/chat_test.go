@@ -0,0 +1,104 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatHistory(t *testing.T) {
+	testhelpers.CleanupDB()
+	player := testhelpers.CreatePlayer()
+
+	for i := 0; i < 30; i++ {
+		assert.Nil(t, models.NewChatMessage(strconv.Itoa(i), 1, player).Save())
+	}
+	models.NewChatMessage("other room", 2, player).Save()
+
+	messages, err := models.GetRoomMessages(1, 0, models.ChatScrollbackSize)
+	assert.Nil(t, err)
+	assert.Equal(t, models.ChatScrollbackSize, len(messages))
+	// oldest first
+	assert.Equal(t, "10", messages[0].Message)
+	assert.Equal(t, "29", messages[19].Message)
+	assert.Equal(t, player.SteamId, messages[0].Player.SteamId)
+
+	older, err := models.GetRoomMessages(1, messages[0].ID, models.ChatScrollbackSize)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(older))
+	assert.Equal(t, "0", older[0].Message)
+	assert.Equal(t, "9", older[9].Message)
+
+	older, _ = models.GetRoomMessages(1, older[0].ID, models.ChatScrollbackSize)
+	assert.Equal(t, 0, len(older))
+}
+
+func TestChatModeration(t *testing.T) {
+	testhelpers.CleanupDB()
+	player := testhelpers.CreatePlayer()
+	mod := testhelpers.CreatePlayerMod()
+
+	assert.Nil(t, player.CanChat(1))
+
+	player.MuteInRoom(1, time.Now().Add(time.Hour), "spam", mod)
+	assert.Equal(t, models.ErrorCodeChatMuted, player.CanChat(1).Code)
+	assert.Nil(t, player.CanChat(2))
+
+	player.UnmuteInRoom(1)
+	assert.Nil(t, player.CanChat(1))
+
+	player.BanUntil(time.Now().Add(time.Hour), models.PlayerBanChat, "spam")
+	assert.Equal(t, models.ErrorCodeChatBanned, player.CanChat(2).Code)
+
+	first := models.NewChatMessage("hi", 1, player)
+	first.Save()
+	second := models.NewChatMessage("spam", 1, player)
+	second.Save()
+	assert.Nil(t, second.Delete())
+
+	messages, _ := models.GetRoomMessages(1, 0, models.ChatScrollbackSize)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, first.ID, messages[0].ID)
+}
+
+func TestChatSpam(t *testing.T) {
+	testhelpers.CleanupDB()
+	player := testhelpers.CreatePlayer()
+
+	for i := 0; i < config.Constants.ChatBurst; i++ {
+		assert.Nil(t, player.CheckChatSpam(1, strconv.Itoa(i)))
+	}
+	tperr := player.CheckChatSpam(1, "too fast")
+	assert.NotNil(t, tperr)
+	assert.Equal(t, models.ErrorCodeChatRateLimited, tperr.Code)
+	assert.True(t, tperr.RetryAfter > 0)
+
+	// other rooms have their own limit
+	assert.Nil(t, player.CheckChatSpam(2, "hello"))
+
+	for i := 0; i < config.Constants.ChatMaxRepeats; i++ {
+		assert.Nil(t, player.CheckChatSpam(3, "same"))
+	}
+	tperr = player.CheckChatSpam(3, "SAME ")
+	assert.NotNil(t, tperr)
+	assert.Equal(t, models.ErrorCodeChatRateLimited, tperr.Code)
+
+	// keep going until the strikes add up to a ban
+	for i := 1; i < config.Constants.ChatSpamStrikes-1; i++ {
+		assert.Equal(t, models.ErrorCodeChatRateLimited, player.CheckChatSpam(1, "spam").Code)
+	}
+	assert.False(t, player.IsBanned(models.PlayerBanChat))
+	tperr = player.CheckChatSpam(1, "spam")
+	assert.Equal(t, models.ErrorCodeChatBanned, tperr.Code)
+	assert.True(t, player.IsBanned(models.PlayerBanChat))
+	assert.Equal(t, models.ErrorCodeChatBanned, player.CanChat(1).Code)
+}
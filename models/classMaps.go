@@ -7,47 +7,131 @@ package models
 import "github.com/TF2Stadium/Helen/helpers"
 
 var teamMap = map[string]int{"red": 0, "blu": 1}
-var sixesClassMap = map[string]int{
-	"scout1":  0,
-	"scout2":  1,
-	"roamer":  2,
-	"pocket":  3,
-	"demoman": 4,
-	"medic":   5,
+var teamNames = []string{"red", "blu"}
+
+// A lobby format. Each team gets one slot per entry in Classes, in that
+// order, with red's slots coming before blu's.
+type Format struct {
+	Type    LobbyType
+	Name    string // used in requests and config, e.g. "sixes"
+	Title   string // shown to players, e.g. "Sixes"
+	Classes []string
+
+	classMap map[string]int
+}
+
+var formats = make(map[LobbyType]*Format)
+var formatNames = make(map[string]*Format)
+var formatList []*Format
+
+// returned for lobby types that haven't been registered, it has no slots
+var unknownFormat = &Format{Name: "unknown", Title: "Unknown", classMap: map[string]int{}}
+
+func RegisterFormat(lobbyType LobbyType, name string, title string, classes []string) *Format {
+	format := &Format{
+		Type:     lobbyType,
+		Name:     name,
+		Title:    title,
+		Classes:  classes,
+		classMap: make(map[string]int),
+	}
+	for i, class := range classes {
+		format.classMap[class] = i
+	}
+
+	UnregisterFormat(lobbyType)
+	formats[lobbyType] = format
+	formatNames[name] = format
+	formatList = append(formatList, format)
+	return format
+}
+
+func UnregisterFormat(lobbyType LobbyType) {
+	old, ok := formats[lobbyType]
+	if !ok {
+		return
+	}
+
+	delete(formats, lobbyType)
+	delete(formatNames, old.Name)
+	for i, f := range formatList {
+		if f == old {
+			formatList = append(formatList[:i], formatList[i+1:]...)
+			break
+		}
+	}
+}
+
+func GetFormatByName(name string) (*Format, bool) {
+	format, ok := formatNames[name]
+	return format, ok
+}
+
+// All registered formats, in the order they were registered
+func FormatList() []*Format {
+	return formatList
+}
+
+func FormatNames() []string {
+	var names []string
+	for _, format := range formatList {
+		names = append(names, format.Name)
+	}
+	return names
+}
+
+func (lobbyType LobbyType) Format() *Format {
+	if format, ok := formats[lobbyType]; ok {
+		return format
+	}
+	return unknownFormat
+}
+
+func (lobbyType LobbyType) IsRegistered() bool {
+	_, ok := formats[lobbyType]
+	return ok
 }
 
-var sixesClassList = []string{"scout1", "scout2", "roamer", "pocket", "demoman", "medic"}
-
-var hlClassMap = map[string]int{
-	"scout":    0,
-	"soldier":  1,
-	"pyro":     2,
-	"demoman":  3,
-	"heavy":    4,
-	"engineer": 5,
-	"medic":    6,
-	"sniper":   7,
-	"spy":      8,
+func (format *Format) TeamSize() int {
+	return len(format.Classes)
 }
 
-var debugClassMap = map[string]int{
-	"scout": 0,
+func (format *Format) NumSlots() int {
+	return 2 * len(format.Classes)
 }
 
-var debugClassList = []string{"scout"}
+func (format *Format) HasClass(class string) bool {
+	_, ok := format.classMap[class]
+	return ok
+}
 
-var hlClassList = []string{"scout", "soldier", "pyro", "demoman", "heavy", "engineer", "medic", "sniper", "spy"}
+func (format *Format) Slot(team int, class int) int {
+	return team*format.TeamSize() + class
+}
 
-var TypeClassMap = map[LobbyType]map[string]int{
-	LobbyTypeHighlander: hlClassMap,
-	LobbyTypeSixes:      sixesClassMap,
-	LobbyTypeDebug:      debugClassMap,
+// The team and class of a slot, the reverse of Slot
+func (format *Format) SlotInfo(slot int) (string, string) {
+	if slot < 0 || slot >= format.NumSlots() {
+		return "", ""
+	}
+	return teamNames[slot/format.TeamSize()], format.Classes[slot%format.TeamSize()]
 }
 
-var TypeClassList = map[LobbyType][]string{
-	LobbyTypeHighlander: hlClassList,
-	LobbyTypeSixes:      sixesClassList,
-	LobbyTypeDebug:      debugClassList,
+func init() {
+	RegisterFormat(LobbyTypeSixes, "sixes", "Sixes",
+		[]string{"scout1", "scout2", "roamer", "pocket", "demoman", "medic"})
+	RegisterFormat(LobbyTypeHighlander, "highlander", "Highlander",
+		[]string{"scout", "soldier", "pyro", "demoman", "heavy", "engineer", "medic", "sniper", "spy"})
+	RegisterFormat(LobbyTypeFours, "fours", "4v4",
+		[]string{"scout", "soldier", "demoman", "medic"})
+	RegisterFormat(LobbyTypeUltiduo, "ultiduo", "Ultiduo",
+		[]string{"soldier", "medic"})
+	RegisterFormat(LobbyTypeBball, "bball", "Bball",
+		[]string{"soldier1", "soldier2"})
+	RegisterFormat(LobbyTypeProlander, "prolander", "Prolander",
+		[]string{"scout", "soldier", "demoman", "medic", "sniper", "flex1", "flex2"})
+	RegisterFormat(LobbyTypeDebug, "debug", "Debug",
+		[]string{"scout"})
 }
 
 func LobbyGetPlayerSlot(lobbytype LobbyType, teamStr string, classStr string) (int, *helpers.TPError) {
@@ -56,21 +140,12 @@ func LobbyGetPlayerSlot(lobbytype LobbyType, teamStr string, classStr string) (i
 		return -1, helpers.NewTPError("Invalid team", -1)
 	}
 
-	var classMap map[string]int
-	switch lobbytype {
-	case LobbyTypeHighlander:
-		classMap = hlClassMap
-	case LobbyTypeSixes:
-		classMap = sixesClassMap
-	case LobbyTypeDebug:
-		classMap = debugClassMap
-	}
-
-	class, ok := classMap[classStr]
+	format := lobbytype.Format()
+	class, ok := format.classMap[classStr]
 
 	if !ok {
 		return -1, helpers.NewTPError("Invalid class", -1)
 	}
 
-	return team*len(classMap) + class, nil
+	return format.Slot(team, class), nil
 }
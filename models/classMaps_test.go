@@ -31,3 +31,45 @@ func TestUgcHighlander(t *testing.T) {
 	res, err = models.LobbyGetPlayerSlot(models.LobbyTypeSixes, "ylw", "demoman")
 	assert.NotNil(t, err)
 }
+
+func TestFormatRegistry(t *testing.T) {
+	format, ok := models.GetFormatByName("ultiduo")
+	assert.True(t, ok)
+	assert.Equal(t, models.LobbyTypeUltiduo, format.Type)
+	assert.Equal(t, 2, format.TeamSize())
+	assert.Equal(t, 4, format.NumSlots())
+
+	res, err := models.LobbyGetPlayerSlot(models.LobbyTypeUltiduo, "blu", "medic")
+	assert.Equal(t, 3, res)
+	assert.Nil(t, err)
+
+	res, err = models.LobbyGetPlayerSlot(models.LobbyTypeProlander, "red", "heavy")
+	assert.NotNil(t, err)
+
+	// unregistered formats have no slots
+	assert.False(t, models.LobbyType(42).IsRegistered())
+	assert.Equal(t, 0, models.LobbyType(42).Format().NumSlots())
+
+	models.RegisterFormat(models.LobbyType(42), "mge", "MGE", []string{"soldier"})
+	defer models.UnregisterFormat(models.LobbyType(42))
+	res, err = models.LobbyGetPlayerSlot(models.LobbyType(42), "blu", "soldier")
+	assert.Equal(t, 1, res)
+	assert.Nil(t, err)
+	assert.Contains(t, models.FormatNames(), "mge")
+}
+
+func TestFormatSlotInfo(t *testing.T) {
+	format := models.LobbyTypeSixes.Format()
+
+	team, class := format.SlotInfo(0)
+	assert.Equal(t, "red", team)
+	assert.Equal(t, "scout1", class)
+
+	team, class = format.SlotInfo(10)
+	assert.Equal(t, "blu", team)
+	assert.Equal(t, "demoman", class)
+
+	team, class = format.SlotInfo(12)
+	assert.Equal(t, "", team)
+	assert.Equal(t, "", class)
+}
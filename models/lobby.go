@@ -19,10 +19,16 @@ import (
 type LobbyType int
 type LobbyState int
 
+// These values are stored in the database, don't change them. Class lists
+// and slot layouts are defined by the format registry in classMaps.go.
 const (
 	LobbyTypeSixes      LobbyType = 6
 	LobbyTypeHighlander LobbyType = 9
 	LobbyTypeDebug      LobbyType = 1
+	LobbyTypeFours      LobbyType = 4
+	LobbyTypeUltiduo    LobbyType = 2
+	LobbyTypeBball      LobbyType = 3
+	LobbyTypeProlander  LobbyType = 7
 )
 
 const (
@@ -36,19 +42,13 @@ const (
 var LobbyServerSettingUp = make(map[uint]time.Time)
 
 var stateString = map[LobbyState]string{
-	LobbyStateWaiting:    "Waiting For Players",
-	LobbyStateInProgress: "Lobby in Progress",
-	LobbyStateEnded:      "Lobby Ended",
+	LobbyStateInitializing: "Initializing",
+	LobbyStateWaiting:      "Waiting For Players",
+	LobbyStateReadyingUp:   "Readying Up",
+	LobbyStateInProgress:   "Lobby in Progress",
+	LobbyStateEnded:        "Lobby Ended",
 }
 
-var FormatMap = map[LobbyType]string{
-	LobbyTypeDebug:      "Debug",
-	LobbyTypeSixes:      "Sixes",
-	LobbyTypeHighlander: "Highlander",
-}
-
-var readyUpLobbyID = make(chan uint)
-
 type LobbySlot struct {
 	ID uint
 	// Lobby    Lobby
@@ -60,6 +60,7 @@ type LobbySlot struct {
 	InGame   bool
 }
 
+// Both passwords are stored encrypted, use Decrypted to get them back
 type ServerRecord struct {
 	ID             uint
 	Host           string
@@ -67,7 +68,7 @@ type ServerRecord struct {
 	RconPassword   string
 }
 
-//Given Lobby IDs are unique, we'll use them for mumble channel names
+// Given Lobby IDs are unique, we'll use them for mumble channel names
 type Lobby struct {
 	gorm.Model
 	MapName string
@@ -80,8 +81,15 @@ type Lobby struct {
 
 	ServerInfo   ServerRecord
 	ServerInfoID uint
+	GameServerID uint `sql:"default:0"` // pool server the lobby is on, if any
+
+	Whitelist int    //whitelist.tf ID
+	Regions   string // comma separated, see GetRegions
 
-	Whitelist int //whitelist.tf ID
+	// private lobbies are only listed for, and joinable by, invited players
+	// and those who know the password
+	Private  bool   `sql:"default:false"`
+	Password string // salted hash, see SetPassword
 
 	Spectators []Player `gorm:"many2many:spectators_players_lobbies"`
 
@@ -89,7 +97,7 @@ type Lobby struct {
 
 	CreatedBySteamID string
 
-	readyUpTimestamp int64 //Stores the timestamp at which the ready up timeout started
+	ReadyUpDeadline int64 `sql:"default:0"` // unix timestamp at which the ready up times out
 }
 
 func NewLobby(mapName string, lobbyType LobbyType, league string, serverInfo ServerRecord, whitelist int, mumble bool) *Lobby {
@@ -175,56 +183,83 @@ func (lobby *Lobby) AddPlayer(player *Player, slot int) *helpers.TPError {
 		return lobbyBanError
 	}
 
-	if slot >= 2*int(lobby.Type) || slot < 0 {
+	if slot >= lobby.Type.Format().NumSlots() || slot < 0 {
 		return badSlotError
 	}
 
-	slotFilled := false
-	if _, err := lobby.GetPlayerIdBySlot(slot); err == nil {
-		slotFilled = true
+	if tperr := lobby.CheckRequirements(player, slot); tperr != nil {
+		return tperr
 	}
 
-	currLobbyId, err := player.GetLobbyId()
+	// read before the new slot exists, after it GetLobbyId could find either
+	currLobbyId, currErr := player.GetLobbyId()
 
-	// if the player is in a different lobby, remove them from that lobby
-	if err == nil && currLobbyId != lobby.ID {
-		curLobby, _ := GetLobbyById(currLobbyId)
-		curLobby.RemovePlayer(player)
-	}
+	// the slot is checked and taken while holding the lobby's lock, the
+	// unique index on (lobby_id, slot) catches anything that slips past
+	changed := []int{slot}
+	tperr := lobby.transaction(func(tx *gorm.DB) *helpers.TPError {
+		count := 0
+		tx.Model(&LobbySlot{}).Where("lobby_id = ? AND slot = ?", lobby.ID, slot).Count(&count)
+		if count != 0 {
+			return filledError
+		}
 
-	// if the slot is occupied, return error
-	if slotFilled {
-		return filledError
+		// in case they are switching slots
+		oldSlot := &LobbySlot{}
+		if tx.Where("player_id = ? AND lobby_id = ?", player.ID, lobby.ID).First(oldSlot).Error == nil {
+			tx.Delete(oldSlot)
+			changed = append(changed, oldSlot.Slot)
+		}
+
+		newSlotObj := &LobbySlot{
+			PlayerId: player.ID,
+			LobbyId:  lobby.ID,
+			Slot:     slot,
+		}
+		if err := tx.Create(newSlotObj).Error; err != nil {
+			helpers.Logger.Debug("Couldn't take slot %d in lobby #%d: %s", slot, lobby.ID, err.Error())
+			return filledError
+		}
+		return nil
+	})
+	if tperr != nil {
+		return tperr
 	}
 
-	// assign the player to a new slot
-	// try to remove them from the old slot (in case they are switching slots)
-	if err == nil && currLobbyId == lobby.ID {
-		lobby.RemovePlayer(player)
+	// if the player was in a different lobby, remove them from that lobby
+	if currErr == nil && currLobbyId != lobby.ID {
+		curLobby, _ := GetLobbyById(currLobbyId)
+		curLobby.RemovePlayer(player)
 	}
 	// try to remove them from spectators
 	lobby.RemoveSpectator(player)
-
-	newSlotObj := &LobbySlot{
-		PlayerId: player.ID,
-		LobbyId:  lobby.ID,
-		Slot:     slot,
-	}
-
-	db.DB.Create(newSlotObj)
+	// a player who takes a slot doesn't need to wait for a match anymore
+	DequeuePlayer(player.SteamId)
 
 	AllowPlayer(lobby.ID, player.SteamId)
-	lobby.OnChange(true)
+	lobby.broadcastSlots(true, changed...)
 	return nil
 }
 
 func (lobby *Lobby) RemovePlayer(player *Player) *helpers.TPError {
-	err := db.DB.Where("player_id = ? AND lobby_id = ?", player.ID, lobby.ID).Delete(&LobbySlot{}).Error
-	if err != nil {
-		return helpers.NewTPError(err.Error(), -1)
+	slot := &LobbySlot{}
+	tperr := lobby.transaction(func(tx *gorm.DB) *helpers.TPError {
+		if tx.Where("player_id = ? AND lobby_id = ?", player.ID, lobby.ID).First(slot).Error != nil {
+			// not in the lobby, nothing to do
+			return nil
+		}
+		if err := tx.Delete(slot).Error; err != nil {
+			return helpers.NewTPError(err.Error(), -1)
+		}
+		return nil
+	})
+	if tperr != nil {
+		return tperr
 	}
 
-	lobby.OnChange(true)
+	if slot.ID != 0 {
+		lobby.broadcastSlots(true, slot.Slot)
+	}
 	return nil
 }
 
@@ -234,27 +269,28 @@ func (lobby *Lobby) BanPlayer(player *Player) {
 }
 
 func (lobby *Lobby) ReadyPlayer(player *Player) *helpers.TPError {
-	slot := &LobbySlot{}
-	err := db.DB.Where("lobby_id = ? AND player_id = ?", lobby.ID, player.ID).First(slot).Error
-	if err != nil {
-		return helpers.NewTPError("Player is not in the lobby.", 5)
-	}
-	slot.Ready = true
-	db.DB.Save(slot)
-	lobby.OnChange(false)
-	return nil
+	return lobby.setReady(player, true)
 }
 
 func (lobby *Lobby) UnreadyPlayer(player *Player) *helpers.TPError {
+	return lobby.setReady(player, false)
+}
+
+func (lobby *Lobby) setReady(player *Player, ready bool) *helpers.TPError {
 	slot := &LobbySlot{}
-	err := db.DB.Where("lobby_id = ? AND player_id = ?", lobby.ID, player.ID).First(slot).Error
-	if err != nil {
-		return helpers.NewTPError("Player is not in the lobby.", 5)
+	tperr := lobby.transaction(func(tx *gorm.DB) *helpers.TPError {
+		err := tx.Where("lobby_id = ? AND player_id = ?", lobby.ID, player.ID).First(slot).Error
+		if err != nil {
+			return helpers.NewTPError("Player is not in the lobby.", 5)
+		}
+		slot.Ready = ready
+		return helpers.NewTPErrorFromError(tx.Save(slot).Error)
+	})
+	if tperr != nil {
+		return tperr
 	}
 
-	slot.Ready = false
-	db.DB.Save(slot)
-	lobby.OnChange(false)
+	lobby.broadcastSlots(false, slot.Slot)
 	return nil
 }
 
@@ -293,54 +329,11 @@ func (lobby *Lobby) UnreadyAllPlayers() error {
 	return err
 }
 
-func ReadyTimeoutListener() {
-	for {
-		id := <-readyUpLobbyID
-		go func() {
-			tick := time.After(time.Second * 30)
-			lobby := &Lobby{}
-			helpers.LockRecord(lobby.ID, lobby)
-			lobby.readyUpTimestamp = time.Now().Unix()
-			helpers.UnlockRecord(lobby.ID, lobby)
-			<-tick
-			db.DB.First(lobby, id)
-
-			if lobby.State != LobbyStateInProgress {
-				helpers.LockRecord(lobby.ID, lobby)
-				defer helpers.UnlockRecord(lobby.ID, lobby)
-				err := lobby.RemoveUnreadyPlayers()
-				if err != nil {
-					helpers.Logger.Critical(err.Error())
-				}
-
-				lobby.UnreadyAllPlayers()
-				if err != nil {
-					helpers.Logger.Critical(err.Error())
-				}
-
-				lobby.State = LobbyStateWaiting
-				lobby.Save()
-			}
-		}()
-	}
-}
-
-func (lobby *Lobby) ReadyUpTimeoutCheck() {
-	readyUpLobbyID <- lobby.ID
-}
-
-func (lobby *Lobby) ReadyUpTimeLeft() int {
-	if lobby.State == LobbyStateReadyingUp {
-		return int(time.Now().Unix() - lobby.readyUpTimestamp)
-	}
-	return 0
-}
-
 func (lobby *Lobby) IsEveryoneReady() bool {
 	var slots []LobbySlot
 	db.DB.Where("lobby_id = ?", lobby.ID).Find(&slots)
 
-	if len(slots) != int(lobby.Type)*2 {
+	if len(slots) != lobby.Type.Format().NumSlots() {
 		return false
 	}
 
@@ -357,7 +350,7 @@ func (lobby *Lobby) AddSpectator(player *Player) *helpers.TPError {
 	if err != nil {
 		return helpers.NewTPError(err.Error(), -1)
 	}
-	lobby.OnChange(false)
+	lobby.broadcastSpectators()
 	return nil
 }
 
@@ -366,7 +359,7 @@ func (lobby *Lobby) RemoveSpectator(player *Player) *helpers.TPError {
 	if err != nil {
 		return helpers.NewTPError(err.Error(), -1)
 	}
-	lobby.OnChange(false)
+	lobby.broadcastSpectators()
 	return nil
 }
 
@@ -380,7 +373,7 @@ func (lobby *Lobby) GetPlayerNumber() int {
 }
 
 func (lobby *Lobby) IsFull() bool {
-	return lobby.GetPlayerNumber() >= 2*int(lobby.Type)
+	return lobby.GetPlayerNumber() >= lobby.Type.Format().NumSlots()
 }
 
 func (lobby *Lobby) IsSlotFilled(slot int) bool {
@@ -403,15 +396,26 @@ func (lobby *Lobby) SetupServer() error {
 	return nil
 }
 
-func (lobby *Lobby) Close(rpc bool) {
-	lobby.State = LobbyStateEnded
+func (lobby *Lobby) Close(rpc bool, triggeredBy string) *helpers.TPError {
+	tperr := lobby.transaction(func(tx *gorm.DB) *helpers.TPError {
+		if tperr := lobby.SetState(LobbyStateEnded, triggeredBy); tperr != nil {
+			return tperr
+		}
+		return helpers.NewTPErrorFromError(tx.Save(lobby).Error)
+	})
+	if tperr != nil {
+		return tperr
+	}
+
 	db.DB.Delete(&lobby.ServerInfo)
 	if rpc {
 		End(lobby.ID)
 	}
+	ReleaseServer(lobby.GameServerID)
+	BroadcastSubList()
 	delete(LobbyServerSettingUp, lobby.ID)
-	db.DB.Save(lobby)
-	helpers.RemoveRecord(lobby.ID, lobby)
+	lobby.StopReadyUpTimer()
+	return nil
 }
 
 // GORM callback
@@ -431,8 +435,7 @@ func (lobby *Lobby) RealAfterSave() {
 
 // If base is true, broadcasts the lobby list update
 func (lobby *Lobby) OnChange(base bool) {
-	if lobby.State == LobbyStateWaiting || lobby.State == LobbyStateInProgress ||
-		lobby.State == LobbyStateReadyingUp {
+	if lobby.broadcastsChanges() {
 		BroadcastLobby(lobby)
 	}
 
@@ -441,8 +444,10 @@ func (lobby *Lobby) OnChange(base bool) {
 	}
 }
 
+// Sends the whole lobby, for changes that don't fit in a lobbyDelta
 func BroadcastLobby(lobby *Lobby) {
-	bytes, _ := DecorateLobbyDataJSON(lobby, true).Encode()
+	version := nextLobbyVersion(lobby.ID)
+	bytes, _ := DecorateLobbySnapshotJSON(lobby, version).Encode()
 	room := strconv.FormatUint(uint64(lobby.ID), 10)
 
 	broadcaster.SendMessageToRoom(room, "lobbyData", string(bytes))
@@ -450,17 +455,26 @@ func BroadcastLobby(lobby *Lobby) {
 }
 
 func BroadcastLobbyToUser(lobby *Lobby, steamid string) {
-	bytes, _ := DecorateLobbyDataJSON(lobby, true).Encode()
+	bytes, _ := DecorateLobbySnapshotJSON(lobby, GetLobbyVersion(lobby.ID)).Encode()
 	broadcaster.SendMessage(steamid, "lobbyData", string(bytes))
 }
 
 func BroadcastLobbyList() {
-	var lobbies []Lobby
-	db.DB.Where("state = ?", LobbyStateWaiting).Order("id desc").Find(&lobbies)
+	lobbies, _ := GetLobbyListFor("")
 	list, err := DecorateLobbyListData(lobbies)
 	if err != nil {
 		helpers.Logger.Warning("Failed to send lobby list: %s", err.Error())
-	} else {
-		broadcaster.SendMessageToRoom(fmt.Sprintf("%s_public", config.Constants.GlobalChatRoom), "lobbyListData", list)
+		return
+	}
+	broadcaster.SendMessageToRoom(fmt.Sprintf("%s_public", config.Constants.GlobalChatRoom), "lobbyListData", list)
+
+	// players invited to private lobbies get those as their own event.
+	// Broadcasts are only kept in order per room or player, so a full list
+	// sent to them could be overwritten by the public one above.
+	for _, steamid := range privateLobbyViewers() {
+		lobbies, _ := GetPrivateLobbyListFor(steamid)
+		if list, err := DecorateLobbyListData(lobbies); err == nil {
+			broadcaster.SendMessage(steamid, "privateLobbyListData", list)
+		}
 	}
 }
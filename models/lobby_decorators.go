@@ -8,6 +8,7 @@ import (
 	"strconv"
 
 	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
 	"github.com/bitly/go-simplejson"
 )
 
@@ -33,26 +34,31 @@ func decorateSlotDetails(lobby *Lobby, slot int, includeDetails bool) *simplejso
 func DecorateLobbyDataJSON(lobby *Lobby, includeDetails bool) *simplejson.Json {
 	lobbyJs := simplejson.New()
 	lobbyJs.Set("id", lobby.ID)
-	lobbyJs.Set("type", FormatMap[lobby.Type])
+	format := lobby.Type.Format()
+	lobbyJs.Set("type", format.Title)
 	lobbyJs.Set("players", lobby.GetPlayerNumber())
 	lobbyJs.Set("map", lobby.MapName)
 	lobbyJs.Set("league", lobby.League)
 	lobbyJs.Set("mumbleRequired", lobby.Mumble)
+	lobbyJs.Set("private", lobby.Private)
+	lobbyJs.Set("passwordRequired", lobby.Password != "")
 
 	var classes []*simplejson.Json
 
-	var classList = TypeClassList[lobby.Type]
-	lobbyJs.Set("maxPlayers", len(classList)*2)
+	lobbyJs.Set("maxPlayers", format.NumSlots())
 
-	for slot, className := range classList {
+	reqs := lobby.GetRequirements()
+	for i, className := range format.Classes {
 		class := simplejson.New()
 
-		class.Set("red", decorateSlotDetails(lobby, slot, includeDetails))
-		class.Set("blu", decorateSlotDetails(lobby, slot+int(lobby.Type), includeDetails))
+		class.Set("red", decorateSlotDetails(lobby, format.Slot(0, i), includeDetails))
+		class.Set("blu", decorateSlotDetails(lobby, format.Slot(1, i), includeDetails))
 		class.Set("class", className)
+		class.Set("requirements", decorateRequirement(effectiveRequirement(reqs, className)))
 		classes = append(classes, class)
 	}
 	lobbyJs.Set("classes", classes)
+	lobbyJs.Set("regions", lobby.GetRegions())
 
 	if !includeDetails {
 		return lobbyJs
@@ -65,16 +71,28 @@ func DecorateLobbyDataJSON(lobby *Lobby, includeDetails bool) *simplejson.Json {
 	lobbyJs.Set("state", lobby.State)
 	lobbyJs.Set("whitelistId", lobby.Whitelist)
 
-	var spectators []*simplejson.Json
-	for _, spectator := range lobby.Spectators {
+	lobbyJs.Set("spectators", decorateSpectators(lobby.Spectators))
+
+	return lobbyJs
+}
+
+// The whole lobby as of version, for clients to apply the following
+// lobbyDeltas to
+func DecorateLobbySnapshotJSON(lobby *Lobby, version int) *simplejson.Json {
+	lobbyJs := DecorateLobbyDataJSON(lobby, true)
+	lobbyJs.Set("version", version)
+	return lobbyJs
+}
+
+func decorateSpectators(spectators []Player) []*simplejson.Json {
+	var list []*simplejson.Json
+	for _, spectator := range spectators {
 		specJs := simplejson.New()
 		specJs.Set("name", spectator.Name)
 		specJs.Set("steamid", spectator.SteamId)
-		spectators = append(spectators, specJs)
+		list = append(list, specJs)
 	}
-	lobbyJs.Set("spectators", spectators)
-
-	return lobbyJs
+	return list
 }
 
 func DecorateLobbyListData(lobbies []Lobby) (string, error) {
@@ -102,7 +120,11 @@ func DecorateLobbyConnectJSON(lobby *Lobby) *simplejson.Json {
 
 	json.Set("id", lobby.ID)
 	json.Set("time", lobby.CreatedAt.Unix())
-	json.Set("password", lobby.ServerInfo.ServerPassword)
+	password, err := lobby.ServerInfo.PlainServerPassword()
+	if err != nil {
+		helpers.Logger.Warning("Couldn't decrypt the password of lobby #%d: %s", lobby.ID, err.Error())
+	}
+	json.Set("password", password)
 
 	game := simplejson.New()
 	game.Set("host", lobby.ServerInfo.Host)
@@ -0,0 +1,143 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/bitly/go-simplejson"
+)
+
+// Every update sent out for a lobby gets the next version. Clients apply a
+// lobbyDelta when its version is the one after theirs, skip older ones, and
+// ask for the whole lobby again when they've missed one.
+// It's kept out of the lobbies table so saving a stale *Lobby can't set it
+// back.
+type LobbyVersion struct {
+	ID      uint
+	LobbyID uint
+	Version int
+}
+
+func nextLobbyVersion(lobbyid uint) int {
+	const bump = "UPDATE lobby_versions SET version = version + 1 WHERE lobby_id = ? RETURNING version"
+
+	var version int
+	err := db.DB.Raw(bump, lobbyid).Row().Scan(&version)
+	if err == sql.ErrNoRows {
+		// first update, another instance might be making the row too
+		if db.DB.Create(&LobbyVersion{LobbyID: lobbyid, Version: 1}).Error == nil {
+			return 1
+		}
+		err = db.DB.Raw(bump, lobbyid).Row().Scan(&version)
+	}
+	if err != nil {
+		helpers.Logger.Warning("Couldn't bump the version of lobby #%d: %s", lobbyid, err.Error())
+	}
+	return version
+}
+
+func GetLobbyVersion(lobbyid uint) int {
+	version := &LobbyVersion{}
+	db.DB.Where("lobby_id = ?", lobbyid).First(version)
+	return version.Version
+}
+
+// Whether players get told about changes to the lobby
+func (lobby *Lobby) broadcastsChanges() bool {
+	return lobby.State == LobbyStateWaiting || lobby.State == LobbyStateInProgress ||
+		lobby.State == LobbyStateReadyingUp
+}
+
+// A JSON patch operation
+func replaceOp(path string, value interface{}) *simplejson.Json {
+	op := simplejson.New()
+	op.Set("op", "replace")
+	op.Set("path", path)
+	op.Set("value", value)
+	return op
+}
+
+// Where a slot is in the lobbyData JSON
+func (lobby *Lobby) slotPath(slot int) string {
+	format := lobby.Type.Format()
+	return fmt.Sprintf("/classes/%d/%s", slot%format.TeamSize(), teamNames[slot/format.TeamSize()])
+}
+
+func decorateLobbyDelta(lobby *Lobby, version int, patch []*simplejson.Json) string {
+	j := simplejson.New()
+	j.Set("id", lobby.ID)
+	j.Set("version", version)
+	j.Set("patch", patch)
+	bytes, _ := j.Encode()
+	return string(bytes)
+}
+
+// Sends the given slots to everyone in the lobby. If list is true, players
+// looking at the lobby list get them too.
+func (lobby *Lobby) broadcastSlots(list bool, slots ...int) {
+	if !lobby.broadcastsChanges() {
+		return
+	}
+
+	version := nextLobbyVersion(lobby.ID)
+	players := replaceOp("/players", lobby.GetPlayerNumber())
+	patch := []*simplejson.Json{players}
+	listPatch := []*simplejson.Json{players}
+	for _, slot := range slots {
+		path := lobby.slotPath(slot)
+		patch = append(patch, replaceOp(path, decorateSlotDetails(lobby, slot, true)))
+		listPatch = append(listPatch, replaceOp(path, decorateSlotDetails(lobby, slot, false)))
+	}
+
+	lobby.sendDelta(decorateLobbyDelta(lobby, version, patch))
+	if list && lobby.State == LobbyStateWaiting {
+		lobby.sendListDelta(decorateLobbyDelta(lobby, version, listPatch))
+	}
+}
+
+func (lobby *Lobby) broadcastSpectators() {
+	if !lobby.broadcastsChanges() {
+		return
+	}
+
+	var spectators []Player
+	db.DB.Model(lobby).Association("Spectators").Find(&spectators)
+
+	version := nextLobbyVersion(lobby.ID)
+	patch := []*simplejson.Json{replaceOp("/spectators", decorateSpectators(spectators))}
+	lobby.sendDelta(decorateLobbyDelta(lobby, version, patch))
+}
+
+func (lobby *Lobby) sendDelta(delta string) {
+	room := strconv.FormatUint(uint64(lobby.ID), 10)
+	broadcaster.SendMessageToRoom(room, "lobbyDelta", delta)
+	broadcaster.SendMessageToRoom(fmt.Sprintf("%s_public", room), "lobbyDelta", delta)
+}
+
+// Private lobbies are only in the lists of their creator and the players
+// invited to them
+func (lobby *Lobby) sendListDelta(delta string) {
+	if !lobby.Private {
+		broadcaster.SendMessageToRoom(fmt.Sprintf("%s_public", config.Constants.GlobalChatRoom), "lobbyListDelta", delta)
+		return
+	}
+
+	var steamids []string
+	db.DB.Model(&LobbyInvite{}).Where("lobby_id = ? AND steam_id <> ?", lobby.ID, lobby.CreatedBySteamID).
+		Pluck("steam_id", &steamids)
+	if lobby.CreatedBySteamID != "" {
+		steamids = append(steamids, lobby.CreatedBySteamID)
+	}
+	for _, steamid := range steamids {
+		broadcaster.SendMessage(steamid, "lobbyListDelta", delta)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/jinzhu/gorm"
+)
+
+// Returned when a lobby couldn't be locked in time, the request can be
+// retried
+const ErrorCodeLobbyBusy = 17
+
+var lobbyBusyError = helpers.NewRetryTPError("The lobby is busy, please try again.", ErrorCodeLobbyBusy, time.Second)
+
+// Serializes handlers working on the same lobby. The key doesn't depend on
+// the *Lobby passed around, so every caller gets the same lock. The lobby
+// is only locked, and has to be unlocked, if it returns nil.
+func LockLobby(id uint) *helpers.TPError {
+	if err := helpers.LockRecord(id, &Lobby{}); err != nil {
+		return lobbyBusyError
+	}
+	return nil
+}
+
+func UnlockLobby(id uint) {
+	helpers.UnlockRecord(id, &Lobby{})
+}
+
+// Runs f in a transaction holding the lobby's row lock, so it can't
+// interleave with other changes to the lobby made by any Helen instance. f
+// has to go through tx, changing the lobby's row through db.DB would wait
+// on the lock held here. Everything is rolled back if f returns an error.
+func (lobby *Lobby) transaction(f func(tx *gorm.DB) *helpers.TPError) *helpers.TPError {
+	tx := db.DB.Begin()
+	if tx.Error != nil {
+		helpers.Logger.Warning("Couldn't start a transaction for lobby #%d: %s", lobby.ID, tx.Error.Error())
+		return lobbyBusyError
+	}
+
+	tx.Exec("SET LOCAL lock_timeout = 5000")
+	if err := tx.Exec("SELECT id FROM lobbies WHERE id = ? FOR UPDATE", lobby.ID).Error; err != nil {
+		helpers.Logger.Warning("Couldn't lock lobby #%d: %s", lobby.ID, err.Error())
+		tx.Rollback()
+		return lobbyBusyError
+	}
+
+	if tperr := f(tx); tperr != nil {
+		tx.Rollback()
+		return tperr
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		helpers.Logger.Warning("Couldn't commit changes to lobby #%d: %s", lobby.ID, err.Error())
+		return lobbyBusyError
+	}
+	return nil
+}
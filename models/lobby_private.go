@@ -0,0 +1,177 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+)
+
+// Returned when someone tries to join a private lobby they can't get into
+const (
+	ErrorCodeNotInvited    = 10
+	ErrorCodeWrongPassword = 11
+)
+
+// A player allowed into a private lobby without the password
+type LobbyInvite struct {
+	ID      uint
+	LobbyID uint
+	SteamId string
+}
+
+func hashLobbyPassword(salt string, password string) string {
+	sum := sha256.Sum256([]byte(salt + password))
+	return hex.EncodeToString(sum[:])
+}
+
+// Sets the join password, an empty one removes it. Only the salted hash is
+// stored.
+func (lobby *Lobby) SetPassword(password string) {
+	if password == "" {
+		lobby.Password = ""
+		return
+	}
+
+	saltBytes := make([]byte, 8)
+	rand.Read(saltBytes)
+	salt := hex.EncodeToString(saltBytes)
+	lobby.Password = salt + "$" + hashLobbyPassword(salt, password)
+	lobby.Private = true
+}
+
+func (lobby *Lobby) CheckPassword(password string) bool {
+	parts := strings.SplitN(lobby.Password, "$", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	hash := hashLobbyPassword(parts[0], password)
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(parts[1])) == 1
+}
+
+// Lets steamid into the lobby, making it private if it wasn't already.
+// The lobby has to be saved afterwards.
+func (lobby *Lobby) Invite(steamid string) error {
+	lobby.Private = true
+	if lobby.IsInvited(steamid) {
+		return nil
+	}
+	return db.DB.Create(&LobbyInvite{LobbyID: lobby.ID, SteamId: steamid}).Error
+}
+
+func (lobby *Lobby) IsInvited(steamid string) bool {
+	count := 0
+	db.DB.Model(&LobbyInvite{}).Where("lobby_id = ? AND steam_id = ?", lobby.ID, steamid).Count(&count)
+	return count != 0
+}
+
+// Checks that player can get into the lobby. The creator, invited players
+// and those already in it don't need the password. player is nil for
+// players that aren't logged in.
+func (lobby *Lobby) CheckAccess(player *Player, password string) *helpers.TPError {
+	if !lobby.Private {
+		return nil
+	}
+
+	if player != nil {
+		if player.SteamId == lobby.CreatedBySteamID || lobby.IsInvited(player.SteamId) {
+			return nil
+		}
+		if id, err := player.GetLobbyId(); err == nil && id == lobby.ID {
+			return nil
+		}
+		if player.IsSpectatingId(lobby.ID) {
+			return nil
+		}
+	}
+
+	if lobby.Password == "" {
+		return helpers.NewTPError("This lobby is invite only.", ErrorCodeNotInvited)
+	}
+	if !lobby.CheckPassword(password) {
+		return helpers.NewTPError("Wrong password.", ErrorCodeWrongPassword)
+	}
+	return nil
+}
+
+// Waiting lobbies that steamid can see in the lobby list: every public one,
+// and the private ones they're invited to or made
+func GetLobbyListFor(steamid string) ([]Lobby, error) {
+	var lobbies []Lobby
+	err := db.DB.Where("state = ?", LobbyStateWaiting).Order("id desc").Find(&lobbies).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var invited []uint
+	if steamid != "" {
+		db.DB.Model(&LobbyInvite{}).Where("steam_id = ?", steamid).Pluck("lobby_id", &invited)
+	}
+
+	var visible []Lobby
+	for _, lobby := range lobbies {
+		if !lobby.Private || (steamid != "" && lobby.CreatedBySteamID == steamid) || containsId(invited, lobby.ID) {
+			visible = append(visible, lobby)
+		}
+	}
+	return visible, nil
+}
+
+// The private lobbies steamid can see. They're sent apart from the public
+// list, see BroadcastLobbyList.
+func GetPrivateLobbyListFor(steamid string) ([]Lobby, error) {
+	lobbies, err := GetLobbyListFor(steamid)
+	if err != nil {
+		return nil, err
+	}
+
+	var private []Lobby
+	for _, lobby := range lobbies {
+		if lobby.Private {
+			private = append(private, lobby)
+		}
+	}
+	return private, nil
+}
+
+func containsId(ids []uint, id uint) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SteamIDs of everyone that sees a different lobby list than the public one
+func privateLobbyViewers() []string {
+	var ids []uint
+	db.DB.Model(&Lobby{}).Where("state = ? AND private = ?", LobbyStateWaiting, true).Pluck("id", &ids)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var steamids []string
+	db.DB.Model(&LobbyInvite{}).Where("lobby_id IN (?)", ids).Pluck("steam_id", &steamids)
+
+	var creators []string
+	db.DB.Model(&Lobby{}).Where("id IN (?)", ids).Pluck("created_by_steam_id", &creators)
+
+	seen := make(map[string]bool)
+	var viewers []string
+	for _, steamid := range append(steamids, creators...) {
+		if steamid != "" && !seen[steamid] {
+			seen[steamid] = true
+			viewers = append(viewers, steamid)
+		}
+	}
+	return viewers
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Helen/config"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+)
+
+var readyUpTimers = make(map[uint]*time.Timer)
+var readyUpTimersLock = &sync.Mutex{}
+
+// How long players in a lobby of this format get to ready up
+func ReadyUpTimeout(lobbyType LobbyType) time.Duration {
+	secs, ok := config.Constants.ReadyUpFormatTimeouts[lobbyType.Format().Name]
+	if !ok {
+		secs = config.Constants.ReadyUpTimeout
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// Stores the ready up deadline on the lobby and starts the timer that
+// removes unready players once it passes
+func (lobby *Lobby) StartReadyUpTimer() {
+	lobby.ReadyUpDeadline = time.Now().Add(ReadyUpTimeout(lobby.Type)).Unix()
+	db.DB.Save(lobby)
+	armReadyUpTimer(lobby.ID, lobby.ReadyUpDeadline)
+}
+
+// Cancels a pending ready up timeout, e.g. when everyone readied early
+func (lobby *Lobby) StopReadyUpTimer() {
+	readyUpTimersLock.Lock()
+	if timer, ok := readyUpTimers[lobby.ID]; ok {
+		timer.Stop()
+		delete(readyUpTimers, lobby.ID)
+	}
+	readyUpTimersLock.Unlock()
+
+	if lobby.ReadyUpDeadline != 0 {
+		lobby.ReadyUpDeadline = 0
+		db.DB.Model(lobby).Update("ready_up_deadline", 0)
+	}
+}
+
+// Seconds left before the ready up times out
+func (lobby *Lobby) ReadyUpTimeLeft() int {
+	if lobby.State != LobbyStateReadyingUp || lobby.ReadyUpDeadline == 0 {
+		return 0
+	}
+
+	left := lobby.ReadyUpDeadline - time.Now().Unix()
+	if left < 0 {
+		return 0
+	}
+	return int(left)
+}
+
+func armReadyUpTimer(lobbyid uint, deadline int64) {
+	readyUpTimersLock.Lock()
+	defer readyUpTimersLock.Unlock()
+
+	if timer, ok := readyUpTimers[lobbyid]; ok {
+		timer.Stop()
+	}
+
+	wait := time.Unix(deadline, 0).Sub(time.Now())
+	readyUpTimers[lobbyid] = time.AfterFunc(wait, func() {
+		readyUpTimeout(lobbyid, deadline)
+	})
+}
+
+func readyUpTimeout(lobbyid uint, deadline int64) {
+	readyUpTimersLock.Lock()
+	delete(readyUpTimers, lobbyid)
+	readyUpTimersLock.Unlock()
+
+	if LockLobby(lobbyid) != nil {
+		// try again in a bit, the lobby would be stuck readying up otherwise
+		readyUpTimersLock.Lock()
+		if _, ok := readyUpTimers[lobbyid]; !ok {
+			readyUpTimers[lobbyid] = time.AfterFunc(time.Second, func() {
+				readyUpTimeout(lobbyid, deadline)
+			})
+		}
+		readyUpTimersLock.Unlock()
+		return
+	}
+	defer UnlockLobby(lobbyid)
+
+	lobby := &Lobby{}
+
+	err := db.DB.First(lobby, lobbyid).Error
+	// the deadline check makes sure a stale timer doesn't cut a newer
+	// ready up short
+	if err != nil || lobby.State != LobbyStateReadyingUp || lobby.ReadyUpDeadline != deadline {
+		return
+	}
+
+	err = lobby.RemoveUnreadyPlayers()
+	if err != nil {
+		helpers.Logger.Critical(err.Error())
+	}
+
+	err = lobby.UnreadyAllPlayers()
+	if err != nil {
+		helpers.Logger.Critical(err.Error())
+	}
+
+	lobby.ReadyUpDeadline = 0
+	lobby.SetState(LobbyStateWaiting, TriggerReadyUpTimeout)
+	lobby.Save()
+}
+
+// Re-arms the timers of lobbies that were readying up when Helen stopped
+func RearmReadyUpTimers() {
+	var lobbies []Lobby
+	err := db.DB.Where("state = ? AND ready_up_deadline <> 0", LobbyStateReadyingUp).Find(&lobbies).Error
+	if err != nil {
+		helpers.Logger.Critical(err.Error())
+		return
+	}
+
+	for _, lobby := range lobbies {
+		armReadyUpTimer(lobby.ID, lobby.ReadyUpDeadline)
+	}
+	helpers.Logger.Debug("Re-armed %d ready up timers", len(lobbies))
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"fmt"
+	"strings"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/bitly/go-simplejson"
+)
+
+// Returned by AddPlayer when the player doesn't meet the lobby's requirements
+const (
+	ErrorCodeNotEnoughHours   = 7
+	ErrorCodeNotEnoughLobbies = 8
+)
+
+// What a player needs to take a slot in a lobby. Class is empty for the
+// requirement that applies to every slot.
+type Requirement struct {
+	ID      uint
+	LobbyID uint
+	Class   string
+	Hours   int // TF2 hours
+	Lobbies int // lobbies played, of any format
+}
+
+// Sets the requirement for a class, or every slot if class is empty
+func (lobby *Lobby) SetRequirement(class string, hours int, lobbies int) *helpers.TPError {
+	if class != "" && !lobby.Type.Format().HasClass(class) {
+		return helpers.NewTPError("Invalid class", -1)
+	}
+
+	req := &Requirement{}
+	db.DB.Where("lobby_id = ? AND class = ?", lobby.ID, class).First(req)
+	req.LobbyID = lobby.ID
+	req.Class = class
+	req.Hours = hours
+	req.Lobbies = lobbies
+
+	if err := db.DB.Save(req).Error; err != nil {
+		return helpers.NewTPError(err.Error(), -1)
+	}
+	return nil
+}
+
+func (lobby *Lobby) GetRequirements() []Requirement {
+	var reqs []Requirement
+	db.DB.Where("lobby_id = ?", lobby.ID).Find(&reqs)
+	return reqs
+}
+
+// The requirement a player has to meet for a class: the stricter of the
+// lobby wide one and the class' own one
+func effectiveRequirement(reqs []Requirement, class string) Requirement {
+	effective := Requirement{Class: class}
+	for _, req := range reqs {
+		if req.Class != "" && req.Class != class {
+			continue
+		}
+		if req.Hours > effective.Hours {
+			effective.Hours = req.Hours
+		}
+		if req.Lobbies > effective.Lobbies {
+			effective.Lobbies = req.Lobbies
+		}
+	}
+	return effective
+}
+
+func (lobby *Lobby) SetRegions(regions []string) {
+	lobby.Regions = strings.Join(regions, ",")
+}
+
+// Regions the lobby is meant for, empty if it's for anyone. They're only
+// shown to players, not enforced: the only region Helen knows for a player
+// is the one they picked themselves.
+func (lobby *Lobby) GetRegions() []string {
+	if lobby.Regions == "" {
+		return nil
+	}
+	return strings.Split(lobby.Regions, ",")
+}
+
+// Checks that player can take slot
+func (lobby *Lobby) CheckRequirements(player *Player, slot int) *helpers.TPError {
+	_, class := lobby.Type.Format().SlotInfo(slot)
+	req := effectiveRequirement(lobby.GetRequirements(), class)
+	if req.Hours == 0 && req.Lobbies == 0 {
+		return nil
+	}
+
+	if player.GameHours < req.Hours {
+		return helpers.NewTPError(fmt.Sprintf("You need at least %d hours to play %s in this lobby.",
+			req.Hours, class), ErrorCodeNotEnoughHours)
+	}
+
+	if req.Lobbies != 0 {
+		stats := &PlayerStats{}
+		if player.StatsID != 0 {
+			db.DB.Preload("PlayedCounts").First(stats, player.StatsID)
+		}
+		if stats.PlayedCountTotal() < req.Lobbies {
+			return helpers.NewTPError(fmt.Sprintf("You need to have played at least %d lobbies to play %s in this lobby.",
+				req.Lobbies, class), ErrorCodeNotEnoughLobbies)
+		}
+	}
+	return nil
+}
+
+func decorateRequirement(req Requirement) *simplejson.Json {
+	j := simplejson.New()
+	j.Set("hours", req.Hours)
+	j.Set("lobbies", req.Lobbies)
+	return j
+}
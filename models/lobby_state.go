@@ -0,0 +1,86 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+)
+
+// Returned when a lobby is asked to move to a state it can't reach from
+// its current one
+const ErrorCodeIllegalTransition = 6
+
+// Triggers for state changes that aren't caused by a player
+const (
+	TriggerLobbyCreate    = "lobbyCreate"
+	TriggerMatchmaker     = "matchmaker"
+	TriggerReadyUpTimeout = "readyUpTimeout"
+	TriggerPauling        = "pauling"
+	TriggerDebug          = "debug"
+)
+
+var lobbyTransitions = map[LobbyState][]LobbyState{
+	LobbyStateInitializing: {LobbyStateWaiting, LobbyStateEnded},
+	LobbyStateWaiting:      {LobbyStateReadyingUp, LobbyStateEnded},
+	LobbyStateReadyingUp:   {LobbyStateWaiting, LobbyStateInProgress, LobbyStateEnded},
+	LobbyStateInProgress:   {LobbyStateEnded},
+	LobbyStateEnded:        {},
+}
+
+// A record of a lobby changing its state, kept to debug stuck lobbies
+type LobbyStateTransition struct {
+	ID          uint
+	CreatedAt   time.Time
+	LobbyID     uint
+	FromState   LobbyState
+	ToState     LobbyState
+	TriggeredBy string
+}
+
+// Trigger string for state changes caused by a player's action
+func PlayerTrigger(player *Player) string {
+	return "player:" + player.SteamId
+}
+
+func CanTransition(from LobbyState, to LobbyState) bool {
+	for _, state := range lobbyTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Moves the lobby to the given state and records the change. Like
+// assigning State directly, the lobby itself still needs to be saved.
+func (lobby *Lobby) SetState(state LobbyState, triggeredBy string) *helpers.TPError {
+	if !CanTransition(lobby.State, state) {
+		return helpers.NewTPError(fmt.Sprintf("Lobby can't go from %s to %s.",
+			stateString[lobby.State], stateString[state]), ErrorCodeIllegalTransition)
+	}
+
+	transition := &LobbyStateTransition{
+		LobbyID:     lobby.ID,
+		FromState:   lobby.State,
+		ToState:     state,
+		TriggeredBy: triggeredBy,
+	}
+	lobby.State = state
+
+	if err := db.DB.Create(transition).Error; err != nil {
+		helpers.Logger.Warning("Failed to record state change for lobby #%d: %s", lobby.ID, err.Error())
+	}
+	return nil
+}
+
+func GetLobbyStateHistory(lobbyid uint) ([]LobbyStateTransition, error) {
+	var history []LobbyStateTransition
+	err := db.DB.Where("lobby_id = ?", lobbyid).Order("id").Find(&history).Error
+	return history, err
+}
@@ -8,11 +8,15 @@ import (
 	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
 	db "github.com/TF2Stadium/Helen/database"
 	"github.com/TF2Stadium/Helen/helpers"
 	"github.com/TF2Stadium/Helen/models"
 	"github.com/TF2Stadium/Helen/testhelpers"
+	"github.com/bitly/go-simplejson"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -119,6 +123,105 @@ func TestLobbyRemove(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestLobbyAddSwitchLobbies(t *testing.T) {
+	testhelpers.CleanupDB()
+	first := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "", models.ServerRecord{0, "", "", ""}, 0, false)
+	first.Save()
+	second := models.NewLobby("cp_granary", models.LobbyTypeSixes, "", models.ServerRecord{0, "", "", ""}, 0, false)
+	second.Save()
+
+	player, playErr := models.NewPlayer("switcher")
+	assert.Nil(t, playErr)
+	player.Save()
+
+	assert.Nil(t, first.AddPlayer(player, 0))
+	assert.Nil(t, second.AddPlayer(player, 3))
+
+	_, err := first.GetPlayerSlot(player)
+	assert.NotNil(t, err)
+	slot, err := second.GetPlayerSlot(player)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, slot)
+
+	id, tperr := player.GetLobbyId()
+	assert.Nil(t, tperr)
+	assert.Equal(t, second.ID, id)
+}
+
+func TestLobbyAddConcurrent(t *testing.T) {
+	testhelpers.CleanupDB()
+	lobby := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "", models.ServerRecord{0, "", "", ""}, 0, false)
+	lobby.Save()
+
+	errs := make(chan *helpers.TPError)
+	for i := 0; i < 6; i++ {
+		player, playErr := models.NewPlayer("race" + fmt.Sprint(i))
+		assert.Nil(t, playErr)
+		player.Save()
+
+		go func() {
+			errs <- lobby.AddPlayer(player, 0)
+		}()
+	}
+
+	// only one of them gets the slot, the others are told it's filled
+	joined := 0
+	for i := 0; i < 6; i++ {
+		if err := <-errs; err == nil {
+			joined++
+		} else {
+			assert.Equal(t, 2, err.Code)
+		}
+	}
+	assert.Equal(t, 1, joined)
+
+	var count int
+	db.DB.Model(&models.LobbySlot{}).Where("lobby_id = ? AND slot = ?", lobby.ID, 0).Count(&count)
+	assert.Equal(t, 1, count)
+}
+
+func TestLobbyVersion(t *testing.T) {
+	testhelpers.CleanupDB()
+	lobby := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "", models.ServerRecord{0, "", "", ""}, 0, false)
+	lobby.State = models.LobbyStateWaiting
+	lobby.Save()
+
+	player, playErr := models.NewPlayer("version")
+	assert.Nil(t, playErr)
+	player.Save()
+
+	// every update sent out bumps it
+	version := models.GetLobbyVersion(lobby.ID)
+	assert.Nil(t, lobby.AddPlayer(player, 0))
+	assert.Equal(t, version+1, models.GetLobbyVersion(lobby.ID))
+	assert.Nil(t, lobby.ReadyPlayer(player))
+	assert.Equal(t, version+2, models.GetLobbyVersion(lobby.ID))
+
+	// and whole lobbies sent out say which one they're at
+	backend := broadcaster.NewMemoryBackend()
+	broadcaster.SetBackend(backend)
+	defer broadcaster.SetBackend(broadcaster.NewMemoryBackend())
+	var sent []broadcaster.Message
+	backend.Subscribe(func(m broadcaster.Message) { sent = append(sent, m) })
+
+	models.BroadcastLobby(lobby)
+	assert.Equal(t, version+3, models.GetLobbyVersion(lobby.ID))
+	assert.Equal(t, 2, len(sent))
+	for _, m := range sent {
+		j, _ := simplejson.NewJson([]byte(m.Content))
+		assert.Equal(t, version+3, j.Get("version").MustInt())
+	}
+	// the list doesn't need it
+	_, ok := models.DecorateLobbyDataJSON(lobby, false).CheckGet("version")
+	assert.False(t, ok)
+
+	// a player who isn't in the lobby doesn't change anything
+	other, _ := models.NewPlayer("version2")
+	other.Save()
+	assert.Nil(t, lobby.RemovePlayer(other))
+	assert.Equal(t, version+3, models.GetLobbyVersion(lobby.ID))
+}
+
 func TestLobbyBan(t *testing.T) {
 	testhelpers.CleanupDB()
 	lobby := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "", models.ServerRecord{0, "", "", ""}, 0, false)
@@ -293,3 +396,205 @@ func TestRemoveUnreadyPlayers(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+func TestLobbyStateTransitions(t *testing.T) {
+	testhelpers.CleanupDB()
+	lobby := testhelpers.CreateLobby()
+	player := testhelpers.CreatePlayer()
+
+	// can't start a lobby that hasn't been filled
+	err := lobby.SetState(models.LobbyStateInProgress, models.PlayerTrigger(player))
+	assert.NotNil(t, err)
+	assert.Equal(t, models.ErrorCodeIllegalTransition, err.Code)
+	assert.Equal(t, models.LobbyStateInitializing, lobby.State)
+
+	assert.Nil(t, lobby.SetState(models.LobbyStateWaiting, models.TriggerLobbyCreate))
+	assert.Nil(t, lobby.SetState(models.LobbyStateReadyingUp, models.PlayerTrigger(player)))
+	assert.Nil(t, lobby.SetState(models.LobbyStateWaiting, models.TriggerReadyUpTimeout))
+	lobby.Save()
+
+	assert.Nil(t, lobby.Close(false, models.PlayerTrigger(player)))
+
+	// ended lobbies stay ended
+	err = lobby.SetState(models.LobbyStateWaiting, models.PlayerTrigger(player))
+	assert.NotNil(t, err)
+
+	history, _ := models.GetLobbyStateHistory(lobby.ID)
+	assert.Equal(t, 4, len(history))
+	assert.Equal(t, models.LobbyStateReadyingUp, history[2].FromState)
+	assert.Equal(t, models.LobbyStateWaiting, history[2].ToState)
+	assert.Equal(t, models.TriggerReadyUpTimeout, history[2].TriggeredBy)
+	assert.Equal(t, models.PlayerTrigger(player), history[3].TriggeredBy)
+}
+
+func TestReadyUpTimer(t *testing.T) {
+	testhelpers.CleanupDB()
+	lobby := testhelpers.CreateLobby()
+	lobby.SetState(models.LobbyStateWaiting, models.TriggerLobbyCreate)
+	lobby.SetState(models.LobbyStateReadyingUp, models.TriggerDebug)
+	lobby.Save()
+
+	lobby.StartReadyUpTimer()
+	assert.True(t, lobby.ReadyUpDeadline > time.Now().Unix())
+	assert.True(t, lobby.ReadyUpTimeLeft() > 0)
+	assert.True(t, lobby.ReadyUpTimeLeft() <= config.Constants.ReadyUpTimeout)
+
+	// the deadline survives a reload
+	lobby2, _ := models.GetLobbyById(lobby.ID)
+	assert.Equal(t, lobby.ReadyUpDeadline, lobby2.ReadyUpDeadline)
+
+	lobby.StopReadyUpTimer()
+	assert.Equal(t, 0, lobby.ReadyUpTimeLeft())
+	lobby2, _ = models.GetLobbyById(lobby.ID)
+	assert.Equal(t, int64(0), lobby2.ReadyUpDeadline)
+}
+
+func TestReadyUpTimeoutPerFormat(t *testing.T) {
+	testhelpers.CleanupDB()
+	config.Constants.ReadyUpFormatTimeouts["highlander"] = 45
+	defer delete(config.Constants.ReadyUpFormatTimeouts, "highlander")
+
+	assert.Equal(t, 45*time.Second, models.ReadyUpTimeout(models.LobbyTypeHighlander))
+	assert.Equal(t, time.Duration(config.Constants.ReadyUpTimeout)*time.Second,
+		models.ReadyUpTimeout(models.LobbyTypeSixes))
+}
+
+func TestLobbyOtherFormat(t *testing.T) {
+	testhelpers.CleanupDB()
+	lobby := models.NewLobby("ultiduo_baloo", models.LobbyTypeUltiduo, "", models.ServerRecord{}, 0, false)
+	lobby.Save()
+
+	for i := 0; i < 4; i++ {
+		player := testhelpers.CreatePlayer()
+		assert.Nil(t, lobby.AddPlayer(player, i))
+	}
+	assert.True(t, lobby.IsFull())
+
+	player := testhelpers.CreatePlayer()
+	assert.NotNil(t, lobby.AddPlayer(player, 4))
+}
+
+func TestLobbyRequirements(t *testing.T) {
+	testhelpers.CleanupDB()
+	lobby := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "ugc", models.ServerRecord{}, 0, false)
+	lobby.SetRegions([]string{"eu"})
+	lobby.Save()
+
+	assert.Nil(t, lobby.SetRequirement("", 500, 0))
+	assert.Nil(t, lobby.SetRequirement("medic", 1000, 5))
+	assert.NotNil(t, lobby.SetRequirement("heavy", 1000, 0))
+
+	player := testhelpers.CreatePlayer()
+	player.GameHours = 800
+	player.Save()
+
+	// regions are only shown, players from anywhere can join
+	assert.Nil(t, lobby.AddPlayer(player, 0))
+
+	// medic needs more hours
+	tperr := lobby.AddPlayer(player, 5)
+	assert.Equal(t, models.ErrorCodeNotEnoughHours, tperr.Code)
+
+	player.GameHours = 1200
+	player.Save()
+	tperr = lobby.AddPlayer(player, 5)
+	assert.Equal(t, models.ErrorCodeNotEnoughLobbies, tperr.Code)
+
+	bytes, _ := models.DecorateLobbyDataJSON(lobby, false).Encode()
+	j, _ := simplejson.NewJson(bytes)
+	medic := j.Get("classes").GetIndex(5).Get("requirements")
+	assert.Equal(t, 1000, medic.Get("hours").MustInt())
+	assert.Equal(t, 5, medic.Get("lobbies").MustInt())
+	scout := j.Get("classes").GetIndex(0).Get("requirements")
+	assert.Equal(t, 500, scout.Get("hours").MustInt())
+}
+
+func TestPrivateLobby(t *testing.T) {
+	testhelpers.CleanupDB()
+	creator := testhelpers.CreatePlayer()
+	invited := testhelpers.CreatePlayer()
+	other := testhelpers.CreatePlayer()
+
+	public := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "ugc", models.ServerRecord{}, 0, false)
+	public.State = models.LobbyStateWaiting
+	public.Save()
+
+	lobby := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "ugc", models.ServerRecord{}, 0, false)
+	lobby.State = models.LobbyStateWaiting
+	lobby.CreatedBySteamID = creator.SteamId
+	lobby.Save()
+	lobby.Invite(invited.SteamId)
+	lobby.Save()
+
+	// invite only
+	assert.Nil(t, lobby.CheckAccess(creator, ""))
+	assert.Nil(t, lobby.CheckAccess(invited, ""))
+	assert.Equal(t, models.ErrorCodeNotInvited, lobby.CheckAccess(other, "").Code)
+	assert.Equal(t, models.ErrorCodeNotInvited, lobby.CheckAccess(nil, "").Code)
+
+	lobby.SetPassword("scrim")
+	lobby.Save()
+	assert.NotEqual(t, "scrim", lobby.Password)
+	assert.Nil(t, lobby.CheckAccess(invited, ""))
+	assert.Equal(t, models.ErrorCodeWrongPassword, lobby.CheckAccess(other, "pug").Code)
+	assert.Nil(t, lobby.CheckAccess(other, "scrim"))
+
+	lobbies, err := models.GetLobbyListFor(other.SteamId)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(lobbies))
+	assert.Equal(t, public.ID, lobbies[0].ID)
+
+	lobbies, _ = models.GetLobbyListFor(invited.SteamId)
+	assert.Equal(t, 2, len(lobbies))
+	lobbies, _ = models.GetLobbyListFor(creator.SteamId)
+	assert.Equal(t, 2, len(lobbies))
+	lobbies, _ = models.GetLobbyListFor("")
+	assert.Equal(t, 1, len(lobbies))
+}
+
+func TestBroadcastLobbyListPrivate(t *testing.T) {
+	testhelpers.CleanupDB()
+
+	backend := broadcaster.NewMemoryBackend()
+	broadcaster.SetBackend(backend)
+	defer broadcaster.SetBackend(broadcaster.NewMemoryBackend())
+	var sent []broadcaster.Message
+	backend.Subscribe(func(m broadcaster.Message) { sent = append(sent, m) })
+
+	invited, _ := models.NewPlayer("listinvited")
+	invited.Save()
+
+	public := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "ugc", models.ServerRecord{}, 0, false)
+	public.State = models.LobbyStateWaiting
+	public.Save()
+	private := models.NewLobby("cp_granary", models.LobbyTypeSixes, "ugc", models.ServerRecord{}, 0, false)
+	private.State = models.LobbyStateWaiting
+	private.Invite(invited.SteamId)
+	private.Save()
+
+	sent = nil
+	models.BroadcastLobbyList()
+
+	// the room and the player are separate queues, so the player only gets
+	// what the public list doesn't have, whichever arrives first
+	var room, personal []broadcaster.Message
+	for _, m := range sent {
+		if m.Room != "" {
+			room = append(room, m)
+		} else if m.SteamId == invited.SteamId {
+			personal = append(personal, m)
+		}
+	}
+
+	assert.Equal(t, 1, len(room))
+	assert.Equal(t, "lobbyListData", room[0].Event)
+	roomList, _ := simplejson.NewJson([]byte(room[0].Content))
+	assert.Equal(t, 1, len(roomList.Get("lobbies").MustArray()))
+	assert.Equal(t, public.ID, uint(roomList.Get("lobbies").GetIndex(0).Get("id").MustInt()))
+
+	assert.Equal(t, 1, len(personal))
+	assert.Equal(t, "privateLobbyListData", personal[0].Event)
+	privateList, _ := simplejson.NewJson([]byte(personal[0].Content))
+	assert.Equal(t, 1, len(privateList.Get("lobbies").MustArray()))
+	assert.Equal(t, private.ID, uint(privateList.Get("lobbies").GetIndex(0).Get("id").MustInt()))
+}
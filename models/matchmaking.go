@@ -0,0 +1,340 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+)
+
+// A player waiting in the matchmaking queue. The queue is kept in the
+// database so every Helen instance sees the same one.
+type QueueEntry struct {
+	ID        uint
+	SteamId   string `sql:"unique"`
+	Type      LobbyType
+	League    string
+	Classes   []string `sql:"-"`
+	ClassList string   // Classes, comma separated
+	QueuedAt  time.Time
+}
+
+// How often matches are made for a format and league, for the ETA
+type MatchInterval struct {
+	ID        uint
+	Type      LobbyType
+	League    string
+	LastMatch time.Time
+	Interval  time.Duration
+}
+
+// A queued player together with the slot the matcher picked for them
+type MatchSlot struct {
+	Entry *QueueEntry
+	Slot  int
+}
+
+type Match struct {
+	Type   LobbyType
+	League string
+	Slots  []MatchSlot
+}
+
+type QueueStatus struct {
+	SteamId  string
+	Type     LobbyType
+	League   string
+	Position int
+	ETA      time.Duration
+}
+
+type queueKey struct {
+	lobbyType LobbyType
+	league    string
+}
+
+// used for the ETA when no match has been made for a format yet
+const defaultMatchInterval = 5 * time.Minute
+
+var MatchmakingMaps = map[LobbyType][]string{
+	LobbyTypeSixes:      {"cp_badlands", "cp_granary", "cp_process_final", "cp_snakewater_final1", "cp_gullywash_final1"},
+	LobbyTypeHighlander: {"pl_upward", "pl_badwater", "koth_viaduct", "cp_steel", "koth_lakeside_final"},
+	LobbyTypeFours:      {"cp_badlands", "koth_product_rc8", "cp_granary"},
+	LobbyTypeUltiduo:    {"ultiduo_baloo", "ultiduo_grove_b4"},
+	LobbyTypeBball:      {"ctf_ballin_sky", "ctf_bball_alpine_b4"},
+	LobbyTypeProlander:  {"cp_process_final", "koth_product_rc8", "pl_upward"},
+	LobbyTypeDebug:      {"cp_badlands"},
+}
+
+func RandomMatchMap(lobbyType LobbyType) string {
+	maps := MatchmakingMaps[lobbyType]
+	if len(maps) == 0 {
+		return ""
+	}
+	return maps[rand.Intn(len(maps))]
+}
+
+// Every queued entry, in the order they were queued
+func getQueue() []*QueueEntry {
+	var entries []*QueueEntry
+	db.DB.Order("queued_at, id").Find(&entries)
+	for _, entry := range entries {
+		entry.Classes = strings.Split(entry.ClassList, ",")
+	}
+	return entries
+}
+
+func addQueueEntry(entry *QueueEntry) error {
+	entry.ID = 0
+	entry.ClassList = strings.Join(entry.Classes, ",")
+	return db.DB.Create(entry).Error
+}
+
+func EnqueuePlayer(player *Player, lobbyType LobbyType, league string, classes []string) *helpers.TPError {
+	if player.ID == 0 {
+		return helpers.NewTPError("Player not in the database", -1)
+	}
+
+	if !lobbyType.IsRegistered() {
+		return helpers.NewTPError("Invalid lobby type", -1)
+	}
+	format := lobbyType.Format()
+
+	if len(classes) == 0 {
+		return helpers.NewTPError("No classes selected", 1)
+	}
+	for _, class := range classes {
+		if !format.HasClass(class) {
+			return helpers.NewTPError("Invalid class", -1)
+		}
+	}
+
+	if _, err := player.GetLobbyId(); err == nil {
+		return helpers.NewTPError("Player is already in a lobby", 2)
+	}
+
+	// the unique steam_id catches the player queueing on two instances at
+	// once
+	err := addQueueEntry(&QueueEntry{
+		SteamId:  player.SteamId,
+		Type:     lobbyType,
+		League:   league,
+		Classes:  classes,
+		QueuedAt: time.Now(),
+	})
+	if err != nil {
+		return helpers.NewTPError("Player is already in the queue", 3)
+	}
+	return nil
+}
+
+// Removes the player from the queue, returns false if they weren't queued
+func DequeuePlayer(steamid string) bool {
+	return db.DB.Where("steam_id = ?", steamid).Delete(&QueueEntry{}).RowsAffected != 0
+}
+
+// Puts entries back into the queue at their original position, used when
+// a matched lobby couldn't be started
+func RequeueEntries(entries []*QueueEntry) {
+	for _, entry := range entries {
+		// fails if they've queued again in the meantime
+		addQueueEntry(entry)
+	}
+}
+
+func GetQueueStatus(steamid string) (QueueStatus, bool) {
+	for _, status := range GetQueueStatuses() {
+		if status.SteamId == steamid {
+			return status, true
+		}
+	}
+	return QueueStatus{}, false
+}
+
+func GetQueueStatuses() []QueueStatus {
+	var intervals []MatchInterval
+	db.DB.Find(&intervals)
+	matchInterval := make(map[queueKey]time.Duration)
+	for _, interval := range intervals {
+		matchInterval[queueKey{interval.Type, interval.League}] = interval.Interval
+	}
+
+	var statuses []QueueStatus
+	positions := make(map[queueKey]int)
+
+	for _, entry := range getQueue() {
+		key := queueKey{entry.Type, entry.League}
+		positions[key]++
+
+		interval, ok := matchInterval[key]
+		if !ok || interval == 0 {
+			interval = defaultMatchInterval
+		}
+		matchesAhead := (positions[key] - 1) / entry.Type.Format().NumSlots()
+
+		statuses = append(statuses, QueueStatus{
+			SteamId:  entry.SteamId,
+			Type:     entry.Type,
+			League:   entry.League,
+			Position: positions[key],
+			ETA:      interval * time.Duration(matchesAhead+1),
+		})
+	}
+
+	return statuses
+}
+
+// Tries to fill every slot of a lobby from the queued entries. Entries are
+// considered in the order they were queued, and an entry that has been
+// matched is never dropped in favour of a later one.
+func matchEntries(lobbyType LobbyType, entries []*QueueEntry) []MatchSlot {
+	numSlots := lobbyType.Format().NumSlots()
+	if numSlots == 0 || len(entries) < numSlots {
+		return nil
+	}
+
+	candidates := make([][]int, len(entries))
+	for i, entry := range entries {
+		for _, class := range entry.Classes {
+			for _, team := range []string{"red", "blu"} {
+				if slot, err := LobbyGetPlayerSlot(lobbyType, team, class); err == nil {
+					candidates[i] = append(candidates[i], slot)
+				}
+			}
+		}
+	}
+
+	slotOwner := make([]int, numSlots)
+	for i := range slotOwner {
+		slotOwner[i] = -1
+	}
+
+	var assign func(entry int, seen []bool) bool
+	assign = func(entry int, seen []bool) bool {
+		for _, slot := range candidates[entry] {
+			if seen[slot] {
+				continue
+			}
+			seen[slot] = true
+			if slotOwner[slot] == -1 || assign(slotOwner[slot], seen) {
+				slotOwner[slot] = entry
+				return true
+			}
+		}
+		return false
+	}
+
+	matched := 0
+	for i := range entries {
+		if assign(i, make([]bool, numSlots)) {
+			matched++
+		}
+		if matched == numSlots {
+			break
+		}
+	}
+
+	if matched != numSlots {
+		return nil
+	}
+
+	slots := make([]MatchSlot, numSlots)
+	for slot, entry := range slotOwner {
+		slots[slot] = MatchSlot{Entry: entries[entry], Slot: slot}
+	}
+	return slots
+}
+
+// Removes and returns every full lobby worth of players that can be built
+// from the queue. Only one instance looks for matches at a time.
+func FindMatches() []Match {
+	if err := helpers.LockRecord(0, &QueueEntry{}); err != nil {
+		return nil
+	}
+	defer helpers.UnlockRecord(0, &QueueEntry{})
+
+	var matches []Match
+	var keys []queueKey
+	grouped := make(map[queueKey][]*QueueEntry)
+
+	for _, entry := range getQueue() {
+		key := queueKey{entry.Type, entry.League}
+		if _, ok := grouped[key]; !ok {
+			keys = append(keys, key)
+		}
+		grouped[key] = append(grouped[key], entry)
+	}
+
+	for _, key := range keys {
+		entries := grouped[key]
+		for {
+			slots := matchEntries(key.lobbyType, entries)
+			if slots == nil {
+				break
+			}
+
+			matched := make(map[*QueueEntry]bool)
+			for _, slot := range slots {
+				matched[slot.Entry] = true
+			}
+
+			var rest []*QueueEntry
+			for _, entry := range entries {
+				if !matched[entry] {
+					rest = append(rest, entry)
+				}
+			}
+			entries = rest
+
+			if takeQueueEntries(slots) {
+				matches = append(matches, Match{key.lobbyType, key.league, slots})
+				updateMatchInterval(key)
+			}
+		}
+	}
+
+	return matches
+}
+
+// Removes the matched entries from the queue. Fails if one of them left the
+// queue since it was read, they're all left in it then.
+func takeQueueEntries(slots []MatchSlot) bool {
+	var ids []uint
+	for _, slot := range slots {
+		ids = append(ids, slot.Entry.ID)
+	}
+
+	tx := db.DB.Begin()
+	if tx.Error != nil {
+		return false
+	}
+	deleted := tx.Where("id IN (?)", ids).Delete(&QueueEntry{})
+	if deleted.Error != nil || deleted.RowsAffected != int64(len(ids)) {
+		tx.Rollback()
+		return false
+	}
+	return tx.Commit().Error == nil
+}
+
+func updateMatchInterval(key queueKey) {
+	now := time.Now()
+	interval := &MatchInterval{}
+	err := db.DB.Where("type = ? AND league = ?", key.lobbyType, key.league).First(interval).Error
+	if err != nil {
+		db.DB.Create(&MatchInterval{Type: key.lobbyType, League: key.league, LastMatch: now})
+		return
+	}
+
+	if interval.Interval == 0 {
+		interval.Interval = defaultMatchInterval
+	}
+	interval.Interval = (3*interval.Interval + now.Sub(interval.LastMatch)) / 4
+	interval.LastMatch = now
+	db.DB.Save(interval)
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models_test
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	helpers.InitLogger()
+}
+
+func TestQueueJoinLeave(t *testing.T) {
+	testhelpers.CleanupDB()
+	player := testhelpers.CreatePlayer()
+
+	err := models.EnqueuePlayer(player, models.LobbyTypeSixes, "etf2l", []string{"garbageman"})
+	assert.NotNil(t, err)
+
+	err = models.EnqueuePlayer(player, models.LobbyTypeSixes, "etf2l", []string{"medic"})
+	assert.Nil(t, err)
+
+	// can't queue twice
+	err = models.EnqueuePlayer(player, models.LobbyTypeSixes, "etf2l", []string{"medic"})
+	assert.NotNil(t, err)
+
+	status, ok := models.GetQueueStatus(player.SteamId)
+	assert.True(t, ok)
+	assert.Equal(t, 1, status.Position)
+
+	assert.True(t, models.DequeuePlayer(player.SteamId))
+	assert.False(t, models.DequeuePlayer(player.SteamId))
+}
+
+func TestQueueMatch(t *testing.T) {
+	testhelpers.CleanupDB()
+	var players []*models.Player
+
+	// two medics, everyone else is happy to play anything but medic
+	classes := []string{"scout1", "scout2", "roamer", "pocket", "demoman"}
+	for i := 0; i < 12; i++ {
+		player, _ := models.NewPlayer("queue" + strconv.Itoa(i))
+		player.Save()
+		players = append(players, player)
+
+		playerClasses := classes
+		if i < 2 {
+			playerClasses = []string{"medic"}
+		}
+		assert.Nil(t, models.EnqueuePlayer(player, models.LobbyTypeSixes, "ugc", playerClasses))
+	}
+
+	// a player queued for a different league shouldn't be matched
+	other := testhelpers.CreatePlayer()
+	models.EnqueuePlayer(other, models.LobbyTypeSixes, "etf2l", classes)
+
+	matches := models.FindMatches()
+	assert.Equal(t, 1, len(matches))
+	assert.Equal(t, 12, len(matches[0].Slots))
+
+	medic, _ := models.LobbyGetPlayerSlot(models.LobbyTypeSixes, "red", "medic")
+	assert.Equal(t, players[0].SteamId, matches[0].Slots[medic].Entry.SteamId)
+
+	for _, player := range players {
+		_, queued := models.GetQueueStatus(player.SteamId)
+		assert.False(t, queued)
+	}
+
+	_, queued := models.GetQueueStatus(other.SteamId)
+	assert.True(t, queued)
+	models.DequeuePlayer(other.SteamId)
+}
+
+func TestQueueNoMatch(t *testing.T) {
+	testhelpers.CleanupDB()
+
+	// twelve players, but nobody plays medic
+	for i := 0; i < 12; i++ {
+		player, _ := models.NewPlayer("nomedic" + strconv.Itoa(i))
+		player.Save()
+		models.EnqueuePlayer(player, models.LobbyTypeSixes, "ugc", []string{"scout1", "scout2"})
+	}
+
+	assert.Equal(t, 0, len(models.FindMatches()))
+
+	for i := 0; i < 12; i++ {
+		models.DequeuePlayer("nomedic" + strconv.Itoa(i))
+	}
+}
+
+func TestQueueRequeue(t *testing.T) {
+	testhelpers.CleanupDB()
+	first := testhelpers.CreatePlayer()
+	second := testhelpers.CreatePlayer()
+
+	assert.Nil(t, models.EnqueuePlayer(first, models.LobbyTypeSixes, "ugc", []string{"medic"}))
+	status, _ := models.GetQueueStatus(first.SteamId)
+	entry := &models.QueueEntry{
+		SteamId:  first.SteamId,
+		Type:     status.Type,
+		League:   status.League,
+		Classes:  []string{"medic"},
+		QueuedAt: time.Now().Add(-time.Minute),
+	}
+	models.DequeuePlayer(first.SteamId)
+	assert.Nil(t, models.EnqueuePlayer(second, models.LobbyTypeSixes, "ugc", []string{"medic"}))
+
+	// back in front of the player who queued after them
+	models.RequeueEntries([]*models.QueueEntry{entry})
+	status, ok := models.GetQueueStatus(first.SteamId)
+	assert.True(t, ok)
+	assert.Equal(t, 1, status.Position)
+	status, _ = models.GetQueueStatus(second.SteamId)
+	assert.Equal(t, 2, status.Position)
+
+	// requeueing someone who's already queued doesn't add them twice
+	models.RequeueEntries([]*models.QueueEntry{entry})
+	assert.Equal(t, 2, len(models.GetQueueStatuses()))
+}
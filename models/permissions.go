@@ -0,0 +1,109 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/helpers/authority"
+	"github.com/bitly/go-simplejson"
+)
+
+// Whether a role can do an action. authority keeps these in memory, this is
+// where they're loaded from. Actions are stored by name so they don't depend
+// on the order of the constants.
+type RolePermission struct {
+	ID      uint
+	Role    authority.AuthRole
+	Action  string
+	Allowed bool
+}
+
+// Adds the default grant for every role and action that isn't in the
+// database yet, then loads them all into authority
+func LoadPermissions() error {
+	var stored []RolePermission
+	if err := db.DB.Find(&stored).Error; err != nil {
+		return err
+	}
+	have := make(map[authority.AuthRole]map[string]bool)
+	for _, perm := range stored {
+		if have[perm.Role] == nil {
+			have[perm.Role] = make(map[string]bool)
+		}
+		have[perm.Role][perm.Action] = true
+	}
+
+	authority.Reset()
+	helpers.InitAuthorization()
+	for role := range helpers.RoleNames {
+		for action, name := range helpers.ActionNames {
+			if have[role][name] {
+				continue
+			}
+			perm := RolePermission{Role: role, Action: name, Allowed: role.Can(action)}
+			if err := db.DB.Create(&perm).Error; err != nil {
+				return err
+			}
+			stored = append(stored, perm)
+		}
+	}
+
+	authority.Reset()
+	for _, perm := range stored {
+		applyPermission(perm)
+	}
+	return nil
+}
+
+func applyPermission(perm RolePermission) {
+	action, ok := helpers.ActionByName(perm.Action)
+	if !ok {
+		return
+	}
+	if perm.Allowed {
+		perm.Role.Allow(action)
+	} else {
+		perm.Role.Disallow(action)
+	}
+}
+
+// Grants or revokes an action, for every player with the role straight away
+func SetPermission(role authority.AuthRole, action authority.AuthAction, allowed bool) *helpers.TPError {
+	if !helpers.RoleExists(role) || !helpers.ActionExists(action) {
+		return helpers.NewTPError("Invalid role or action", -1)
+	}
+	if role == helpers.RoleAdmin && action == helpers.ActionManagePermissions && !allowed {
+		return helpers.NewTPError("Administrators can't lose the permission to manage permissions.", -1)
+	}
+
+	perm := RolePermission{}
+	name := helpers.ActionNames[action]
+	db.DB.Where("role = ? AND action = ?", role, name).First(&perm)
+	perm.Role = role
+	perm.Action = name
+	perm.Allowed = allowed
+	if err := db.DB.Save(&perm).Error; err != nil {
+		return helpers.NewTPErrorFromError(err)
+	}
+
+	applyPermission(perm)
+	return nil
+}
+
+// Every role with the actions it can do
+func DecoratePermissionsJSON() *simplejson.Json {
+	j := simplejson.New()
+	for role, roleName := range helpers.RoleNames {
+		actions := []string{}
+		for action, actionName := range helpers.ActionNames {
+			if role.Can(action) {
+				actions = append(actions, actionName)
+			}
+		}
+		j.Set(roleName, actions)
+	}
+	return j
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models_test
+
+import (
+	"sync"
+	"testing"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissions(t *testing.T) {
+	testhelpers.CleanupDB()
+	defer func() {
+		testhelpers.CleanupDB()
+		models.LoadPermissions()
+	}()
+
+	assert.Nil(t, models.LoadPermissions())
+	count := 0
+	db.DB.Model(&models.RolePermission{}).Count(&count)
+	assert.Equal(t, len(helpers.RoleNames)*len(helpers.ActionNames), count)
+
+	assert.True(t, helpers.RoleMod.Can(helpers.ActionChatDelete))
+	assert.False(t, helpers.RolePlayer.Can(helpers.ActionChatDelete))
+	assert.True(t, helpers.RoleAdmin.Can(helpers.ActionCloseLobby))
+
+	assert.Nil(t, models.SetPermission(helpers.RoleMod, helpers.ActionChatDelete, false))
+	assert.False(t, helpers.RoleMod.Can(helpers.ActionChatDelete))
+	assert.Nil(t, models.SetPermission(helpers.RolePlayer, helpers.ActionViewAdminLog, true))
+	assert.True(t, helpers.RolePlayer.Can(helpers.ActionViewAdminLog))
+
+	assert.NotNil(t, models.SetPermission(helpers.RoleAdmin, helpers.ActionManagePermissions, false))
+
+	// stored grants win over the defaults
+	assert.Nil(t, models.LoadPermissions())
+	assert.False(t, helpers.RoleMod.Can(helpers.ActionChatDelete))
+	assert.True(t, helpers.RolePlayer.Can(helpers.ActionViewAdminLog))
+	db.DB.Model(&models.RolePermission{}).Count(&count)
+	assert.Equal(t, len(helpers.RoleNames)*len(helpers.ActionNames), count)
+}
+
+// run with -race, requests check permissions while they're being changed
+func TestSetPermissionConcurrent(t *testing.T) {
+	testhelpers.CleanupDB()
+	defer func() {
+		testhelpers.CleanupDB()
+		models.LoadPermissions()
+	}()
+	assert.Nil(t, models.LoadPermissions())
+
+	stop := make(chan bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					helpers.RoleMod.Can(helpers.ActionChatDelete)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 20; i++ {
+		assert.Nil(t, models.SetPermission(helpers.RoleMod, helpers.ActionChatDelete, i%2 == 0))
+	}
+	close(stop)
+	wg.Wait()
+	assert.False(t, helpers.RoleMod.Can(helpers.ActionChatDelete))
+}
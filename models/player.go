@@ -32,6 +32,8 @@ type PlayerBan struct {
 	Until    time.Time
 	Reason   string
 	Active   bool `sql:"default:true"`
+
+	BannedByID uint `sql:"default:0"` // 0 for automatic bans
 }
 
 // SETTINGS
@@ -103,7 +105,7 @@ func GetPlayerBySteamId(steamid string) (*Player, *helpers.TPError) {
 
 func GetPlayerWithStats(steamid string) (*Player, *helpers.TPError) {
 	var player = Player{}
-	err := db.DB.Where("steam_id = ?", steamid).Preload("Stats").First(&player).Error
+	err := db.DB.Where("steam_id = ?", steamid).Preload("Stats").Preload("Stats.PlayedCounts").First(&player).Error
 	if err != nil {
 		return nil, helpers.NewTPError("Player is not in the database", -1)
 	}
@@ -223,19 +225,18 @@ func (player *Player) IsBanned(t PlayerBanType) bool {
 }
 
 func (player *Player) BanUntil(tim time.Time, t PlayerBanType, reason string) error {
-	ban := PlayerBan{
-		PlayerID: player.ID,
-		Type:     t,
-		Until:    tim,
-		Reason:   reason,
-	}
-
-	return db.DB.Create(&ban).Error
+	_, err := player.Ban(t, tim, reason, nil)
+	return err
 }
 
 func (player *Player) Unban(t PlayerBanType) error {
-	return db.DB.Model(&PlayerBan{}).Where("player_id = ? AND type = ? AND active = TRUE", player.ID, t).
+	err := db.DB.Model(&PlayerBan{}).Where("player_id = ? AND type = ? AND active = TRUE", player.ID, t).
 		Update("active", "FALSE").Error
+	if err != nil {
+		return err
+	}
+	player.updateBanColumns()
+	return nil
 }
 
 func (player *Player) GetActiveBans() ([]*PlayerBan, error) {
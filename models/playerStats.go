@@ -8,6 +8,16 @@ type PlayerStats struct {
 	ID                    uint
 	PlayedSixesCount      int `sql:"played_sixes_count",default:"0"`
 	PlayedHighlanderCount int `sql:"played_highlander_count",default:"0"`
+
+	// counts for every other registered format
+	PlayedCounts []PlayerStatsFormatCount
+}
+
+type PlayerStatsFormatCount struct {
+	ID            uint
+	PlayerStatsID uint
+	Type          LobbyType
+	Count         int `sql:"default:0"`
 }
 
 func NewPlayerStats() PlayerStats {
@@ -22,6 +32,17 @@ func (ps *PlayerStats) PlayedCountSet(lt LobbyType, value int) {
 		ps.PlayedSixesCount = value
 	case LobbyTypeHighlander:
 		ps.PlayedHighlanderCount = value
+	default:
+		if !lt.IsRegistered() {
+			return
+		}
+		for i := range ps.PlayedCounts {
+			if ps.PlayedCounts[i].Type == lt {
+				ps.PlayedCounts[i].Count = value
+				return
+			}
+		}
+		ps.PlayedCounts = append(ps.PlayedCounts, PlayerStatsFormatCount{Type: lt, Count: value})
 	}
 }
 
@@ -32,14 +53,23 @@ func (ps *PlayerStats) PlayedCountGet(lt LobbyType) int {
 	case LobbyTypeHighlander:
 		return ps.PlayedHighlanderCount
 	}
+	for _, count := range ps.PlayedCounts {
+		if count.Type == lt {
+			return count.Count
+		}
+	}
 	return 0
 }
 
 func (ps *PlayerStats) PlayedCountIncrease(lt LobbyType) {
-	switch lt {
-	case LobbyTypeSixes:
-		ps.PlayedSixesCount += 1
-	case LobbyTypeHighlander:
-		ps.PlayedHighlanderCount += 1
+	ps.PlayedCountSet(lt, ps.PlayedCountGet(lt)+1)
+}
+
+// Lobbies played across all formats
+func (ps *PlayerStats) PlayedCountTotal() int {
+	total := ps.PlayedSixesCount + ps.PlayedHighlanderCount
+	for _, count := range ps.PlayedCounts {
+		total += count.Count
 	}
+	return total
 }
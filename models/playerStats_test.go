@@ -38,3 +38,29 @@ func TestLobbiesPlayed(t *testing.T) {
 	assert.Equal(t, 6, stats2.PlayedCountGet(models.LobbyTypeSixes))
 	assert.Equal(t, 8, stats2.PlayedCountGet(models.LobbyTypeHighlander))
 }
+
+func TestLobbiesPlayedOtherFormats(t *testing.T) {
+	testhelpers.CleanupDB()
+	stats1 := &models.PlayerStats{}
+
+	stats1.PlayedCountIncrease(models.LobbyTypeUltiduo)
+	stats1.PlayedCountIncrease(models.LobbyTypeUltiduo)
+	stats1.PlayedCountIncrease(models.LobbyTypeFours)
+	stats1.PlayedCountIncrease(models.LobbyTypeSixes)
+	// unregistered formats aren't counted
+	stats1.PlayedCountIncrease(models.LobbyType(100))
+
+	assert.Equal(t, 2, stats1.PlayedCountGet(models.LobbyTypeUltiduo))
+	assert.Equal(t, 1, stats1.PlayedCountGet(models.LobbyTypeFours))
+	assert.Equal(t, 0, stats1.PlayedCountGet(models.LobbyType(100)))
+	assert.Equal(t, 4, stats1.PlayedCountTotal())
+	database.DB.Save(stats1)
+
+	var stats2 models.PlayerStats
+	err := database.DB.Preload("PlayedCounts").First(&stats2, stats1.ID).Error
+	assert.Nil(t, err)
+
+	assert.Equal(t, 2, stats2.PlayedCountGet(models.LobbyTypeUltiduo))
+	assert.Equal(t, 1, stats2.PlayedCountGet(models.LobbyTypeFours))
+	assert.Equal(t, 1, stats2.PlayedCountGet(models.LobbyTypeSixes))
+}
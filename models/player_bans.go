@@ -0,0 +1,113 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"fmt"
+	"time"
+
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/bitly/go-simplejson"
+)
+
+// Returned by CheckBan
+const ErrorCodeBanned = 16
+
+var BanTypeNames = map[PlayerBanType]string{
+	PlayerBanJoin:   "join",
+	PlayerBanCreate: "create",
+	PlayerBanChat:   "chat",
+	PlayerBanFull:   "full",
+}
+
+var banMessages = map[PlayerBanType]string{
+	PlayerBanJoin:   "You've been banned from joining lobbies",
+	PlayerBanCreate: "You've been banned from creating lobbies",
+	PlayerBanChat:   "You've been banned from chatting",
+	PlayerBanFull:   "You've been banned",
+}
+
+// Bans the player, bannedBy is the moderator that did it or nil for
+// automatic bans
+func (player *Player) Ban(t PlayerBanType, until time.Time, reason string, bannedBy *Player) (*PlayerBan, error) {
+	ban := &PlayerBan{
+		PlayerID: player.ID,
+		Type:     t,
+		Until:    until,
+		Reason:   reason,
+	}
+	if bannedBy != nil {
+		ban.BannedByID = bannedBy.ID
+	}
+
+	if err := db.DB.Create(ban).Error; err != nil {
+		return nil, err
+	}
+	player.updateBanColumns()
+	return ban, nil
+}
+
+// Every ban the player has had, newest first
+func (player *Player) GetBans() ([]*PlayerBan, error) {
+	var bans []*PlayerBan
+	err := db.DB.Where("player_id = ?", player.ID).Order("id desc").Find(&bans).Error
+	return bans, err
+}
+
+// Returns an error if the player is banned from doing t, or fully banned
+func (player *Player) CheckBan(t PlayerBanType) *helpers.TPError {
+	for _, banType := range []PlayerBanType{t, PlayerBanFull} {
+		if banned, until := player.IsBannedWithTime(banType); banned {
+			return helpers.NewTPError(fmt.Sprintf("%s until %s.", banMessages[banType],
+				until.UTC().Format(time.RFC1123)), ErrorCodeBanned)
+		}
+	}
+	return nil
+}
+
+// Keeps the BannedXUntil columns in line with the player's active bans
+func (player *Player) updateBanColumns() {
+	columns := map[PlayerBanType]*int64{
+		PlayerBanJoin:   &player.BannedPlayUntil,
+		PlayerBanCreate: &player.BannedCreateUntil,
+		PlayerBanChat:   &player.BannedChatUntil,
+		PlayerBanFull:   &player.BannedFullUntil,
+	}
+	for t, column := range columns {
+		*column = 0
+		if banned, until := player.IsBannedWithTime(t); banned {
+			*column = until.Unix()
+		}
+	}
+
+	db.DB.Model(player).Updates(map[string]interface{}{
+		"banned_play_until":   player.BannedPlayUntil,
+		"banned_create_until": player.BannedCreateUntil,
+		"banned_chat_until":   player.BannedChatUntil,
+		"banned_full_until":   player.BannedFullUntil,
+	})
+}
+
+func DecoratePlayerBanJSON(ban *PlayerBan) *simplejson.Json {
+	j := simplejson.New()
+	j.Set("id", ban.ID)
+	j.Set("type", BanTypeNames[ban.Type])
+	j.Set("reason", ban.Reason)
+	j.Set("createdAt", ban.CreatedAt.Unix())
+	j.Set("until", ban.Until.Unix())
+	j.Set("active", ban.Active && ban.Until.After(time.Now()))
+	return j
+}
+
+func DecoratePlayerBanListJSON(bans []*PlayerBan) *simplejson.Json {
+	list := make([]*simplejson.Json, len(bans))
+	for i, ban := range bans {
+		list[i] = DecoratePlayerBanJSON(ban)
+	}
+	j := simplejson.New()
+	j.Set("bans", list)
+	return j
+}
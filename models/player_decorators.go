@@ -5,6 +5,8 @@
 package models
 
 import (
+	"strings"
+
 	"github.com/TF2Stadium/Helen/helpers"
 	"github.com/bitly/go-simplejson"
 )
@@ -29,8 +31,14 @@ func DecoratePlayerProfileJson(p *Player) *simplejson.Json {
 
 	// stats
 	s := simplejson.New()
-	s.Set("playedHighlanderCount", p.Stats.PlayedHighlanderCount)
-	s.Set("playedSixesCount", p.Stats.PlayedSixesCount)
+	for _, format := range FormatList() {
+		if format.Type == LobbyTypeDebug {
+			continue
+		}
+		// playedSixesCount, playedHighlanderCount...
+		key := "played" + strings.ToUpper(format.Name[:1]) + format.Name[1:] + "Count"
+		s.Set(key, p.Stats.PlayedCountGet(format.Type))
+	}
 
 	// info
 	j.Set("createdAt", p.CreatedAt)
@@ -42,7 +50,19 @@ func DecoratePlayerProfileJson(p *Player) *simplejson.Json {
 	j.Set("id", p.ID)
 	j.Set("role", helpers.RoleNames[p.Role])
 
-	// TODO ban info
+	var substitutions []*simplejson.Json
+	subs, _ := p.GetSubstitutions()
+	for _, sub := range subs {
+		entry := simplejson.New()
+		entry.Set("lobbyId", sub.LobbyID)
+		entry.Set("reason", sub.Reason)
+		entry.Set("createdAt", sub.CreatedAt.Unix())
+		substitutions = append(substitutions, entry)
+	}
+	j.Set("substitutions", substitutions)
+
+	bans, _ := p.GetBans()
+	j.Set("bans", DecoratePlayerBanListJSON(bans).Get("bans"))
 
 	return j
 }
@@ -53,7 +73,7 @@ func DecoratePlayerSummaryJson(p *Player) *simplejson.Json {
 	j.Set("avatar", p.Avatar)
 	j.Set("gameHours", p.GameHours)
 	j.Set("profileUrl", p.Profileurl)
-	j.Set("lobbiesPlayed", p.Stats.PlayedHighlanderCount+p.Stats.PlayedSixesCount)
+	j.Set("lobbiesPlayed", p.Stats.PlayedCountTotal())
 	j.Set("steamid", p.SteamId)
 	j.Set("name", p.Name)
 	j.Set("tags", decoratePlayerTags(p))
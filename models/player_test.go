@@ -170,3 +170,55 @@ func TestPlayerBanning(t *testing.T) {
 	assert.False(t, player2.IsBanned(models.PlayerBanChat))
 	assert.False(t, player2.IsBanned(models.PlayerBanFull))
 }
+
+func TestPlayerBanModeration(t *testing.T) {
+	testhelpers.CleanupDB()
+	player := testhelpers.CreatePlayer()
+	mod := testhelpers.CreatePlayer()
+
+	assert.Nil(t, player.CheckBan(models.PlayerBanCreate))
+
+	until := time.Now().Add(time.Hour)
+	ban, err := player.Ban(models.PlayerBanCreate, until, "trolling", mod)
+	assert.Nil(t, err)
+	assert.Equal(t, mod.ID, ban.BannedByID)
+
+	tperr := player.CheckBan(models.PlayerBanCreate)
+	assert.NotNil(t, tperr)
+	assert.Equal(t, models.ErrorCodeBanned, tperr.Code)
+	assert.Nil(t, player.CheckBan(models.PlayerBanJoin))
+
+	player2, _ := models.GetPlayerBySteamId(player.SteamId)
+	assert.Equal(t, until.Unix(), player2.BannedCreateUntil)
+	assert.Equal(t, int64(0), player2.BannedPlayUntil)
+
+	// full bans stop everything
+	player.BanUntil(until, models.PlayerBanFull, "more trolling")
+	assert.NotNil(t, player.CheckBan(models.PlayerBanJoin))
+
+	player.Unban(models.PlayerBanCreate)
+	player.Unban(models.PlayerBanFull)
+	assert.Nil(t, player.CheckBan(models.PlayerBanCreate))
+	player2, _ = models.GetPlayerBySteamId(player.SteamId)
+	assert.Equal(t, int64(0), player2.BannedCreateUntil)
+
+	bans, err := player.GetBans()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(bans))
+	assert.Equal(t, "more trolling", bans[0].Reason)
+}
+
+func TestPlayerProfileStats(t *testing.T) {
+	testhelpers.CleanupDB()
+	player := testhelpers.CreatePlayer()
+	player.Stats.PlayedCountSet(models.LobbyTypeSixes, 3)
+	player.Stats.PlayedCountSet(models.LobbyTypeFours, 2)
+
+	// keys come from the format names, not their titles
+	stats := models.DecoratePlayerProfileJson(player).Get("stats")
+	assert.Equal(t, 3, stats.Get("playedSixesCount").MustInt())
+	assert.Equal(t, 0, stats.Get("playedHighlanderCount").MustInt())
+	assert.Equal(t, 2, stats.Get("playedFoursCount").MustInt())
+	_, ok := stats.CheckGet("playedDebugCount")
+	assert.False(t, ok)
+}
@@ -5,9 +5,16 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"net/rpc"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	db "github.com/TF2Stadium/Helen/database"
 	"github.com/TF2Stadium/Helen/helpers"
 )
 
@@ -29,36 +36,252 @@ type Args struct {
 	SteamId2  string
 }
 
-var Pauling *rpc.Client
-
 type Event map[string]interface{}
 
+type PaulingState int
+
+const (
+	PaulingDisconnected PaulingState = iota
+	PaulingConnecting
+	PaulingConnected
+)
+
+var PaulingStateNames = map[PaulingState]string{
+	PaulingDisconnected: "disconnected",
+	PaulingConnecting:   "connecting",
+	PaulingConnected:    "connected",
+}
+
+type PaulingStatus struct {
+	State     PaulingState
+	Since     time.Time // when State last changed
+	LastError string
+	Attempts  int // failed connection attempts since the last successful one
+	Queued    int // calls waiting to be sent once Pauling is back
+}
+
+var ErrPaulingDown = errors.New("Pauling is unavailable, please try again later.")
+
+const (
+	paulingMinBackoff = time.Second
+	paulingMaxBackoff = time.Second * 30
+	maxQueuedCalls    = 256
+	eventPollInterval = time.Millisecond * 500
+)
+
+type queuedCall struct {
+	method string
+	args   *Args
+}
+
+var paulingLock = &sync.RWMutex{}
+var paulingClient *rpc.Client
+var paulingStatus = PaulingStatus{State: PaulingDisconnected, Since: time.Now()}
+var queuedCalls []queuedCall
+
+// set when the connected Pauling doesn't have WaitEvent yet
+var paulingPollsEvents bool
+var reconnectPauling = make(chan bool, 1)
+var startSupervisor = &sync.Once{}
+
+// Starts the goroutine that keeps Helen connected to Pauling, reconnecting
+// with backoff whenever the connection drops. Calling it again drops the
+// current connection and connects to the port in config.
 func PaulingConnect() {
 	if config.Constants.ServerMockUp {
 		return
 	}
-	helpers.Logger.Debug("Connecting to Pauling on port %s", config.Constants.PaulingPort)
-	client, err := rpc.DialHTTP("tcp", "localhost:"+config.Constants.PaulingPort)
+	startSupervisor.Do(func() {
+		go paulingSupervisor()
+	})
+
+	paulingLock.Lock()
+	client := paulingClient
+	paulingClient = nil
+	setPaulingState(PaulingDisconnected, nil)
+	paulingLock.Unlock()
+
+	if client != nil {
+		client.Close()
+	}
+	select {
+	case reconnectPauling <- true:
+	default:
+	}
+}
+
+func GetPaulingStatus() PaulingStatus {
+	paulingLock.RLock()
+	defer paulingLock.RUnlock()
+
+	status := paulingStatus
+	status.Queued = len(queuedCalls)
+	return status
+}
+
+func setPaulingState(state PaulingState, err error) {
+	if paulingStatus.State != state {
+		paulingStatus.Since = time.Now()
+	}
+	paulingStatus.State = state
 	if err != nil {
-		helpers.Logger.Fatal(err)
+		paulingStatus.LastError = err.Error()
+	}
+}
+
+func paulingSupervisor() {
+	for {
+		<-reconnectPauling
+		backoff := paulingMinBackoff
+
+		for {
+			paulingLock.Lock()
+			setPaulingState(PaulingConnecting, nil)
+			paulingLock.Unlock()
+
+			helpers.Logger.Debug("Connecting to Pauling on port %s", config.Constants.PaulingPort)
+			client, err := rpc.DialHTTP("tcp", "localhost:"+config.Constants.PaulingPort)
+			if err == nil {
+				paulingLock.Lock()
+				paulingClient = client
+				paulingPollsEvents = false
+				paulingStatus.Attempts = 0
+				setPaulingState(PaulingConnected, nil)
+				paulingLock.Unlock()
+
+				helpers.Logger.Debug("Connected!")
+				flushQueuedCalls()
+				notifyActiveLobbies("Connection to the game servers has been restored.")
+				break
+			}
+
+			paulingLock.Lock()
+			paulingStatus.Attempts++
+			setPaulingState(PaulingDisconnected, err)
+			paulingLock.Unlock()
+
+			helpers.Logger.Warning("Couldn't connect to Pauling, retrying in %s: %s", backoff, err.Error())
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > paulingMaxBackoff {
+				backoff = paulingMaxBackoff
+			}
+		}
+	}
+}
+
+// Called when a call on client failed because of the connection. Only the
+// first failure on a given client starts a reconnect.
+func paulingDown(client *rpc.Client, err error) {
+	paulingLock.Lock()
+	if paulingClient != client {
+		paulingLock.Unlock()
+		return
+	}
+	paulingClient = nil
+	setPaulingState(PaulingDisconnected, err)
+	paulingLock.Unlock()
+
+	client.Close()
+	helpers.Logger.Warning("Lost connection to Pauling: %s", err.Error())
+	notifyActiveLobbies("Lost connection to the game servers, reconnecting...")
+
+	select {
+	case reconnectPauling <- true:
+	default:
+	}
+}
+
+// errors returned by the RPC service itself are rpc.ServerError, anything
+// else means the connection is broken
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, ok := err.(rpc.ServerError)
+	return !ok
+}
+
+func callPauling(method string, args interface{}, reply interface{}) error {
+	paulingLock.RLock()
+	client := paulingClient
+	paulingLock.RUnlock()
+
+	if client == nil {
+		return ErrPaulingDown
+	}
+
+	err := client.Call(method, args, reply)
+	if isConnectionError(err) {
+		paulingDown(client, err)
+		return ErrPaulingDown
+	}
+	return err
+}
+
+// Like callPauling, but if Pauling is down the call is queued and sent once
+// the connection is back. Used for calls that nobody waits on.
+func callPaulingOrQueue(method string, args *Args) error {
+	err := callPauling(method, args, &Args{})
+	if err != ErrPaulingDown {
+		return err
+	}
+
+	paulingLock.Lock()
+	defer paulingLock.Unlock()
+
+	if len(queuedCalls) >= maxQueuedCalls {
+		helpers.Logger.Warning("Pauling call queue is full, dropping %s for lobby #%d", method, args.Id)
+		return ErrPaulingDown
+	}
+	queuedCalls = append(queuedCalls, queuedCall{method, args})
+	return nil
+}
+
+func flushQueuedCalls() {
+	paulingLock.Lock()
+	calls := queuedCalls
+	queuedCalls = nil
+	paulingLock.Unlock()
+
+	for i, call := range calls {
+		err := callPauling(call.method, call.args, &Args{})
+		if err == ErrPaulingDown {
+			// lost the connection again, keep the rest for next time
+			paulingLock.Lock()
+			queuedCalls = append(calls[i:], queuedCalls...)
+			paulingLock.Unlock()
+			return
+		}
+		if err != nil {
+			helpers.Logger.Warning("Queued %s for lobby #%d failed: %s", call.method, call.args.Id, err.Error())
+		}
 	}
+}
 
-	Pauling = client
-	helpers.Logger.Debug("Connected!")
+func notifyActiveLobbies(message string) {
+	var ids []uint
+	db.DB.Model(&Lobby{}).
+		Where("state IN (?)", []LobbyState{LobbyStateWaiting, LobbyStateReadyingUp, LobbyStateInProgress}).
+		Pluck("id", &ids)
+
+	for _, id := range ids {
+		broadcaster.SendMessageToRoom(fmt.Sprintf("%d_public", id), "sendNotification", message)
+	}
 }
 
 func AllowPlayer(lobbyId uint, steamId string) error {
 	if config.Constants.ServerMockUp {
 		return nil
 	}
-	return Pauling.Call("Pauling.AllowPlayer", &Args{Id: lobbyId, SteamId: steamId}, &Args{})
+	return callPaulingOrQueue("Pauling.AllowPlayer", &Args{Id: lobbyId, SteamId: steamId})
 }
 
 func DisallowPlayer(lobbyId uint, steamId string) error {
 	if config.Constants.ServerMockUp {
 		return nil
 	}
-	return Pauling.Call("Pauling.DisallowPlayer", &Args{Id: lobbyId, SteamId: steamId}, &Args{})
+	return callPaulingOrQueue("Pauling.DisallowPlayer", &Args{Id: lobbyId, SteamId: steamId})
 }
 
 func SetupServer(lobbyId uint, info ServerRecord, lobbyType LobbyType, league string,
@@ -67,6 +290,11 @@ func SetupServer(lobbyId uint, info ServerRecord, lobbyType LobbyType, league st
 		return nil
 	}
 
+	info, err := info.Decrypted()
+	if err != nil {
+		return err
+	}
+
 	args := &Args{
 		Id:        lobbyId,
 		Info:      info,
@@ -74,7 +302,7 @@ func SetupServer(lobbyId uint, info ServerRecord, lobbyType LobbyType, league st
 		League:    league,
 		Whitelist: whitelist,
 		Map:       mapName}
-	return Pauling.Call("Pauling.SetupServer", args, &Args{})
+	return callPauling("Pauling.SetupServer", args, &Args{})
 }
 
 func VerifyInfo(info ServerRecord) error {
@@ -82,12 +310,52 @@ func VerifyInfo(info ServerRecord) error {
 		return nil
 	}
 
-	return Pauling.Call("Pauling.VerifyInfo", &info, &Args{})
+	info, err := info.Decrypted()
+	if err != nil {
+		return err
+	}
+	return callPauling("Pauling.VerifyInfo", &info, &Args{})
 }
 
 func End(lobbyId uint) {
 	if config.Constants.ServerMockUp {
 		return
 	}
-	Pauling.Call("Pauling.End", &Args{Id: lobbyId}, &Args{})
+	callPaulingOrQueue("Pauling.End", &Args{Id: lobbyId})
+}
+
+// Blocks until Pauling has an event to send. Paulings without WaitEvent are
+// polled with GetEvent instead, which returns an "empty" event if there's
+// nothing to send.
+func WaitEvent() (Event, error) {
+	paulingLock.RLock()
+	poll := paulingPollsEvents
+	paulingLock.RUnlock()
+
+	if !poll {
+		event := make(Event)
+		err := callPauling("Pauling.WaitEvent", &Args{}, &event)
+		if err == nil || !strings.Contains(err.Error(), "can't find method") {
+			return event, err
+		}
+
+		helpers.Logger.Warning("Pauling doesn't have WaitEvent, polling GetEvent instead")
+		paulingLock.Lock()
+		paulingPollsEvents = true
+		paulingLock.Unlock()
+	}
+
+	time.Sleep(eventPollInterval)
+	event := make(Event)
+	err := callPauling("Pauling.GetEvent", &Args{}, &event)
+	return event, err
+}
+
+func SetupVerifier(info *ServerBootstrap) error {
+	bootstrap := *info
+	var err error
+	if bootstrap.Info, err = info.Info.Decrypted(); err != nil {
+		return err
+	}
+	return callPauling("Pauling.SetupVerifier", &bootstrap, &struct{}{})
 }
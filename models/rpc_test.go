@@ -0,0 +1,62 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models_test
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaulingCalls(t *testing.T) {
+	testhelpers.CleanupDB()
+	pauling, err := testhelpers.StartFakePauling()
+	assert.Nil(t, err)
+	defer pauling.Close()
+
+	info := models.ServerRecord{Host: "localhost:27015", RconPassword: "rcon"}
+	assert.Nil(t, models.VerifyInfo(info))
+	verified := pauling.CallsTo("VerifyInfo")
+	assert.Equal(t, 1, len(verified))
+	assert.Equal(t, "localhost:27015", verified[0].(*models.ServerRecord).Host)
+
+	lobby := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "ugc", info, 0, false)
+	lobby.Save()
+	assert.Nil(t, lobby.SetupServer())
+
+	player := testhelpers.CreatePlayer()
+	assert.Nil(t, lobby.AddPlayer(player, 0))
+	allowed := pauling.CallsTo("AllowPlayer")
+	assert.Equal(t, 1, len(allowed))
+	assert.Equal(t, lobby.ID, allowed[0].(*models.Args).Id)
+	assert.Equal(t, player.SteamId, allowed[0].(*models.Args).SteamId)
+
+	lobby.BanPlayer(player)
+	assert.Equal(t, 1, len(pauling.CallsTo("DisallowPlayer")))
+
+	lobby.Close(true, models.TriggerDebug)
+	ended := pauling.CallsTo("End")
+	assert.Equal(t, 1, len(ended))
+	assert.Equal(t, lobby.ID, ended[0].(*models.Args).Id)
+}
+
+func TestPaulingFailure(t *testing.T) {
+	testhelpers.CleanupDB()
+	pauling, err := testhelpers.StartFakePauling()
+	assert.Nil(t, err)
+	defer pauling.Close()
+
+	pauling.Fail("VerifyInfo", "Couldn't connect to the server")
+	err = models.VerifyInfo(models.ServerRecord{Host: "localhost:27015"})
+	assert.NotNil(t, err)
+	assert.Equal(t, "Couldn't connect to the server", err.Error())
+	// a failed call doesn't mean the connection is gone
+	assert.Equal(t, models.PaulingConnected, models.GetPaulingStatus().State)
+
+	pauling.Succeed("VerifyInfo")
+	assert.Nil(t, models.VerifyInfo(models.ServerRecord{Host: "localhost:27015"}))
+}
@@ -0,0 +1,218 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"time"
+
+	"github.com/TF2Stadium/Helen/config"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/jinzhu/gorm"
+)
+
+type ServerHealth int
+
+const (
+	ServerHealthy ServerHealth = iota
+	ServerUnhealthy
+	ServerDisabled // taken out of the pool by an admin
+)
+
+var ServerHealthNames = map[ServerHealth]string{
+	ServerHealthy:   "healthy",
+	ServerUnhealthy: "unhealthy",
+	ServerDisabled:  "disabled",
+}
+
+// A game server registered by an admin, which lobbies get put on
+type GameServer struct {
+	gorm.Model
+	Host         string `sql:"unique"`
+	RconPassword string // encrypted, see server_secrets.go
+	Region       string
+	Capacity     int // player slots, lobbies with more slots than this won't be put on it
+
+	Health    ServerHealth `sql:"default:0"`
+	LastError string
+	CheckedAt time.Time
+
+	InUse   bool `sql:"default:false"`
+	LobbyID uint `sql:"default:0"` // lobby the server is reserved for
+}
+
+func NewGameServer(host string, rconpwd string, region string, capacity int) (*GameServer, error) {
+	encrypted, err := encryptSecret(rconpwd)
+	if err != nil {
+		return nil, err
+	}
+	return &GameServer{
+		Host:         host,
+		RconPassword: encrypted,
+		Region:       region,
+		Capacity:     capacity,
+	}, nil
+}
+
+func (server *GameServer) Save() error {
+	return db.DB.Save(server).Error
+}
+
+func GetGameServerById(id uint) (*GameServer, *helpers.TPError) {
+	server := &GameServer{}
+	if err := db.DB.First(server, id).Error; err != nil {
+		return nil, helpers.NewTPError("Server not in the database", -1)
+	}
+	return server, nil
+}
+
+func GetGameServers() ([]*GameServer, error) {
+	var servers []*GameServer
+	err := db.DB.Order("region, id").Find(&servers).Error
+	return servers, err
+}
+
+// The record for a lobby on this server, with both passwords encrypted
+func (server *GameServer) Record(serverPassword string) (ServerRecord, error) {
+	encrypted, err := encryptSecret(serverPassword)
+	if err != nil {
+		return ServerRecord{}, err
+	}
+	return ServerRecord{
+		Host:           server.Host,
+		RconPassword:   server.RconPassword,
+		ServerPassword: encrypted,
+	}, nil
+}
+
+// Checks the server through Pauling and updates its health. Disabled servers
+// stay disabled, and nothing changes if Pauling itself is down.
+func (server *GameServer) Verify() error {
+	record, err := server.Record("")
+	if err != nil {
+		// not the server's fault
+		return err
+	}
+	err = VerifyInfo(record)
+	if err == ErrPaulingDown {
+		return err
+	}
+
+	server.CheckedAt = time.Now()
+	if err != nil {
+		server.LastError = err.Error()
+		if server.Health == ServerHealthy {
+			server.Health = ServerUnhealthy
+			helpers.Logger.Warning("Server #%d (%s) is unhealthy: %s", server.ID, server.Host, err.Error())
+		}
+	} else if server.Health == ServerUnhealthy {
+		server.Health = ServerHealthy
+		server.LastError = ""
+	}
+
+	db.DB.Model(server).Updates(map[string]interface{}{
+		"health":     server.Health,
+		"last_error": server.LastError,
+		"checked_at": server.CheckedAt,
+	})
+	return err
+}
+
+// Finds a free healthy server with enough slots for the format and marks it
+// as in use. Servers in region are tried first, an empty region means any.
+// Servers that fail verification are marked unhealthy and skipped.
+func ReserveServer(lobbyType LobbyType, region string) (*GameServer, *helpers.TPError) {
+	tried := make(map[uint]bool)
+	for {
+		server, mockup := pickServer(lobbyType, region, tried)
+		if mockup {
+			// lets lobbies be made without a pool while testing
+			return &GameServer{}, nil
+		}
+		if server == nil {
+			return nil, helpers.NewTPError("No servers available, please try again later.", -1)
+		}
+		tried[server.ID] = true
+
+		// verifying takes a while, the server is already marked as in use so
+		// nobody else can take it in the meantime
+		err := server.Verify()
+		if err == nil {
+			return server, nil
+		}
+		ReleaseServer(server.ID)
+		if err == ErrPaulingDown {
+			return nil, helpers.NewTPError(err.Error(), -1)
+		}
+	}
+}
+
+// Marks the first free server that hasn't been tried yet as in use. mockup
+// is true if there's no pool and ServerMockUp is on.
+func pickServer(lobbyType LobbyType, region string, tried map[uint]bool) (server *GameServer, mockup bool) {
+	var servers []*GameServer
+	db.DB.Where("health = ? AND in_use = ? AND capacity >= ?",
+		ServerHealthy, false, lobbyType.Format().NumSlots()).
+		Order("id").Find(&servers)
+
+	if len(servers) == 0 && len(tried) == 0 && config.Constants.ServerMockUp {
+		return nil, true
+	}
+
+	for _, server := range sortByRegion(servers, region) {
+		if tried[server.ID] {
+			continue
+		}
+		// other Helen instances share the pool, only one of them gets to
+		// flip in_use
+		taken := db.DB.Model(&GameServer{}).Where("id = ? AND in_use = ?", server.ID, false).
+			Update("in_use", true)
+		if taken.Error != nil || taken.RowsAffected == 0 {
+			continue
+		}
+		server.InUse = true
+		return server, false
+	}
+	return nil, false
+}
+
+// servers in region first, then the rest
+func sortByRegion(servers []*GameServer, region string) []*GameServer {
+	if region == "" {
+		return servers
+	}
+
+	var sorted, rest []*GameServer
+	for _, server := range servers {
+		if server.Region == region {
+			sorted = append(sorted, server)
+		} else {
+			rest = append(rest, server)
+		}
+	}
+	return append(sorted, rest...)
+}
+
+// Records which lobby a reserved server is being used for
+func (server *GameServer) AssignLobby(lobby *Lobby) {
+	if server.ID == 0 {
+		return
+	}
+	server.LobbyID = lobby.ID
+	db.DB.Model(server).Update("lobby_id", lobby.ID)
+
+	lobby.GameServerID = server.ID
+	db.DB.Model(lobby).Update("game_server_id", server.ID)
+}
+
+// Puts a server back into the pool
+func ReleaseServer(id uint) {
+	if id == 0 {
+		return
+	}
+
+	db.DB.Model(&GameServer{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"in_use": false, "lobby_id": 0})
+}
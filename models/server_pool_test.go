@@ -0,0 +1,156 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models_test
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/config"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserveServer(t *testing.T) {
+	testhelpers.CleanupDB()
+	pauling, err := testhelpers.StartFakePauling()
+	assert.Nil(t, err)
+	defer pauling.Close()
+
+	eu, _ := models.NewGameServer("eu.tf2stadium.com:27015", "rcon", "eu", 12)
+	eu.Save()
+	na, _ := models.NewGameServer("na.tf2stadium.com:27015", "rcon", "na", 18)
+	na.Save()
+
+	// the eu server is too small for highlander
+	server, tperr := models.ReserveServer(models.LobbyTypeHighlander, "eu")
+	assert.Nil(t, tperr)
+	assert.Equal(t, na.ID, server.ID)
+
+	_, tperr = models.ReserveServer(models.LobbyTypeHighlander, "")
+	assert.NotNil(t, tperr)
+
+	server, tperr = models.ReserveServer(models.LobbyTypeSixes, "eu")
+	assert.Nil(t, tperr)
+	assert.Equal(t, eu.ID, server.ID)
+
+	record, err := server.Record("pwd")
+	assert.Nil(t, err)
+	lobby := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "etf2l", record, 0, false)
+	lobby.Save()
+	server.AssignLobby(lobby)
+	assert.Equal(t, "eu.tf2stadium.com:27015", lobby.ServerInfo.Host)
+
+	server, _ = models.GetGameServerById(eu.ID)
+	assert.True(t, server.InUse)
+	assert.Equal(t, lobby.ID, server.LobbyID)
+
+	lobby.Close(false, models.TriggerDebug)
+	server, _ = models.GetGameServerById(eu.ID)
+	assert.False(t, server.InUse)
+	assert.Equal(t, uint(0), server.LobbyID)
+}
+
+func TestReserveServerUnhealthy(t *testing.T) {
+	testhelpers.CleanupDB()
+	pauling, err := testhelpers.StartFakePauling()
+	assert.Nil(t, err)
+	defer pauling.Close()
+
+	server, _ := models.NewGameServer("eu.tf2stadium.com:27015", "rcon", "eu", 12)
+	server.Save()
+
+	pauling.Fail("VerifyInfo", "Wrong RCON password")
+	_, tperr := models.ReserveServer(models.LobbyTypeSixes, "")
+	assert.NotNil(t, tperr)
+
+	server, _ = models.GetGameServerById(server.ID)
+	assert.Equal(t, models.ServerUnhealthy, server.Health)
+	assert.Equal(t, "Wrong RCON password", server.LastError)
+	assert.False(t, server.InUse)
+
+	// unhealthy servers aren't tried again until they pass a check
+	pauling.Succeed("VerifyInfo")
+	_, tperr = models.ReserveServer(models.LobbyTypeSixes, "")
+	assert.NotNil(t, tperr)
+
+	assert.Nil(t, server.Verify())
+	assert.Equal(t, models.ServerHealthy, server.Health)
+	_, tperr = models.ReserveServer(models.LobbyTypeSixes, "")
+	assert.Nil(t, tperr)
+}
+
+func TestReserveServerPaulingDown(t *testing.T) {
+	testhelpers.CleanupDB()
+	pauling, err := testhelpers.StartFakePauling()
+	assert.Nil(t, err)
+
+	server, _ := models.NewGameServer("eu.tf2stadium.com:27015", "rcon", "eu", 12)
+	server.Save()
+
+	pauling.Close()
+	_, tperr := models.ReserveServer(models.LobbyTypeSixes, "")
+	assert.NotNil(t, tperr)
+
+	// the server isn't blamed for Pauling being down
+	server, _ = models.GetGameServerById(server.ID)
+	assert.Equal(t, models.ServerHealthy, server.Health)
+	assert.False(t, server.InUse)
+}
+
+func TestRotateServerKey(t *testing.T) {
+	testhelpers.CleanupDB()
+	oldKey := "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	newKey := "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+	config.Constants.ServerRecordKey = oldKey
+	defer func() { config.Constants.ServerRecordKey = "" }()
+
+	server, _ := models.NewGameServer("eu.tf2stadium.com:27015", "rcon", "eu", 12)
+	server.Save()
+	assert.True(t, helpers.IsEncrypted(server.RconPassword))
+
+	record, err := server.Record("pwd")
+	assert.Nil(t, err)
+	assert.True(t, helpers.IsEncrypted(record.ServerPassword))
+	db.DB.Save(&record)
+
+	// stored before encryption was turned on
+	legacy := models.ServerRecord{Host: "na.tf2stadium.com:27015", ServerPassword: "pwd2", RconPassword: "rcon2"}
+	db.DB.Save(&legacy)
+
+	oldKeyBytes, _ := helpers.ParseKey(oldKey)
+	newKeyBytes, _ := helpers.ParseKey(newKey)
+	count, err := models.RotateServerKey(oldKeyBytes, newKeyBytes)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, count)
+
+	config.Constants.ServerRecordKey = newKey
+	server, _ = models.GetGameServerById(server.ID)
+	record, _ = server.Record("")
+	plain, err := record.Decrypted()
+	assert.Nil(t, err)
+	assert.Equal(t, "rcon", plain.RconPassword)
+
+	db.DB.First(&legacy, legacy.ID)
+	assert.True(t, helpers.IsEncrypted(legacy.RconPassword))
+	plain, err = legacy.Decrypted()
+	assert.Nil(t, err)
+	assert.Equal(t, "pwd2", plain.ServerPassword)
+	assert.Equal(t, "rcon2", plain.RconPassword)
+
+	// the old key doesn't work anymore
+	config.Constants.ServerRecordKey = oldKey
+	_, err = legacy.Decrypted()
+	assert.NotNil(t, err)
+
+	// nothing is stored as plaintext with a broken key
+	config.Constants.ServerRecordKey = "not a key"
+	_, err = models.NewGameServer("na.tf2stadium.com:27015", "rcon", "na", 12)
+	assert.NotNil(t, err)
+	_, err = server.Record("pwd")
+	assert.NotNil(t, err)
+}
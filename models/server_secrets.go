@@ -0,0 +1,121 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"github.com/TF2Stadium/Helen/config"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+)
+
+// The key server passwords are encrypted with, nil if none is set.
+// SetupConstants already refuses to start with an invalid key.
+func serverKey() ([]byte, error) {
+	if config.Constants.ServerRecordKey == "" {
+		return nil, nil
+	}
+	return helpers.ParseKey(config.Constants.ServerRecordKey)
+}
+
+func encryptSecret(value string) (string, error) {
+	key, err := serverKey()
+	if err != nil {
+		return "", err
+	}
+	return helpers.Encrypt(key, value)
+}
+
+func decryptSecret(value string) (string, error) {
+	key, err := serverKey()
+	if err != nil {
+		return "", err
+	}
+	return helpers.Decrypt(key, value)
+}
+
+// A copy of the record with plaintext passwords, only for sending to
+// Pauling. Never store or broadcast it.
+func (info ServerRecord) Decrypted() (ServerRecord, error) {
+	var err error
+
+	if info.ServerPassword, err = decryptSecret(info.ServerPassword); err != nil {
+		return info, err
+	}
+	if info.RconPassword, err = decryptSecret(info.RconPassword); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+func (info ServerRecord) PlainServerPassword() (string, error) {
+	return decryptSecret(info.ServerPassword)
+}
+
+func reencrypt(value string, oldKey []byte, newKey []byte) (string, error) {
+	plain, err := helpers.Decrypt(oldKey, value)
+	if err != nil {
+		return "", err
+	}
+	return helpers.Encrypt(newKey, plain)
+}
+
+// Re-encrypts the passwords of every server record and pool server with
+// newKey. Values that aren't encrypted yet get encrypted too. Either every
+// row is updated or none are. Returns the number of rows updated.
+func RotateServerKey(oldKey []byte, newKey []byte) (int, error) {
+	tx := db.DB.Begin()
+
+	var records []ServerRecord
+	if err := tx.Find(&records).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	var servers []GameServer
+	if err := tx.Find(&servers).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	for _, record := range records {
+		serverPwd, err := reencrypt(record.ServerPassword, oldKey, newKey)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		rconPwd, err := reencrypt(record.RconPassword, oldKey, newKey)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		err = tx.Model(&record).Updates(map[string]interface{}{
+			"server_password": serverPwd,
+			"rcon_password":   rconPwd,
+		}).Error
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	for _, server := range servers {
+		rconPwd, err := reencrypt(server.RconPassword, oldKey, newKey)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		if err := tx.Model(&server).Update("rcon_password", rconPwd).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return len(records) + len(servers), nil
+}
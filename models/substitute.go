@@ -0,0 +1,175 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models
+
+import (
+	"fmt"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/controllers/broadcaster"
+	db "github.com/TF2Stadium/Helen/database"
+	"github.com/TF2Stadium/Helen/helpers"
+	"github.com/bitly/go-simplejson"
+	"github.com/jinzhu/gorm"
+)
+
+// Why a player needed to be substituted
+const (
+	SubReasonDisconnected = "disconnected"
+	SubReasonReported     = "reported"
+)
+
+// A slot in a running lobby that was left by a player and can be claimed
+// by anyone else. Once filled it stays as a record of the leaver.
+type Substitute struct {
+	gorm.Model
+	LobbyID  uint
+	Slot     int
+	LeaverID uint
+	Leaver   Player
+	Reason   string
+	Filled   bool `sql:"default:false"`
+	SubID    uint `sql:"default:0"` // player that took the slot
+}
+
+// Takes player's slot away and advertises it as needing a sub
+func NewSubstitute(lobby *Lobby, player *Player, reason string) (*Substitute, *helpers.TPError) {
+	slot, err := lobby.GetPlayerSlot(player)
+	if err != nil {
+		return nil, helpers.NewTPError("Player not in the lobby", -1)
+	}
+
+	tperr := LockLobby(lobby.ID)
+	if tperr == nil {
+		tperr = lobby.RemovePlayer(player)
+		UnlockLobby(lobby.ID)
+	}
+	if tperr != nil {
+		return nil, tperr
+	}
+	DisallowPlayer(lobby.ID, player.SteamId)
+
+	sub := &Substitute{
+		LobbyID:  lobby.ID,
+		Slot:     slot,
+		LeaverID: player.ID,
+		Reason:   reason,
+	}
+	if err := db.DB.Create(sub).Error; err != nil {
+		return nil, helpers.NewTPError(err.Error(), -1)
+	}
+
+	BroadcastSubList()
+	return sub, nil
+}
+
+func GetSubstituteById(id uint) (*Substitute, *helpers.TPError) {
+	sub := &Substitute{}
+	if err := db.DB.First(sub, id).Error; err != nil {
+		return nil, helpers.NewTPError("Substitute not found", -1)
+	}
+	return sub, nil
+}
+
+// Unfilled subs in public lobbies that are still running
+func GetOpenSubstitutes() ([]*Substitute, error) {
+	var subs []*Substitute
+	err := db.DB.Joins("INNER JOIN lobbies ON lobbies.id = substitutes.lobby_id").
+		Where("substitutes.filled = ? AND lobbies.state = ? AND lobbies.private = ?",
+			false, LobbyStateInProgress, false).
+		Order("substitutes.id").Find(&subs).Error
+	return subs, err
+}
+
+// Puts player into the slot. Pauling is told to let them in through
+// AddPlayer. The caller has to hold the sub's lock.
+func (sub *Substitute) Fill(player *Player) *helpers.TPError {
+	if sub.Filled {
+		return helpers.NewTPError("This slot has already been taken.", 2)
+	}
+	if sub.LeaverID == player.ID {
+		return helpers.NewTPError("You can't substitute yourself.", -1)
+	}
+
+	lobby, tperr := GetLobbyById(sub.LobbyID)
+	if tperr != nil {
+		return tperr
+	}
+	if lobby.State != LobbyStateInProgress {
+		return helpers.NewTPError("Lobby isn't in progress anymore.", -1)
+	}
+	if tperr := lobby.CheckAccess(player, ""); tperr != nil {
+		return tperr
+	}
+
+	if id, err := player.GetLobbyId(); err == nil && id != lobby.ID {
+		return helpers.NewTPError("You're already playing in another lobby.", -1)
+	}
+
+	if tperr = LockLobby(lobby.ID); tperr == nil {
+		tperr = lobby.AddPlayer(player, sub.Slot)
+		UnlockLobby(lobby.ID)
+	}
+	if tperr != nil {
+		return tperr
+	}
+
+	sub.Filled = true
+	sub.SubID = player.ID
+	db.DB.Save(sub)
+
+	BroadcastSubList()
+	return nil
+}
+
+// Times the player left a lobby and needed a sub, newest first
+func (player *Player) GetSubstitutions() ([]*Substitute, error) {
+	var subs []*Substitute
+	err := db.DB.Where("leaver_id = ?", player.ID).Order("id desc").Find(&subs).Error
+	return subs, err
+}
+
+func DecorateSubstituteJSON(sub *Substitute) *simplejson.Json {
+	j := simplejson.New()
+	j.Set("id", sub.ID)
+	j.Set("lobbyId", sub.LobbyID)
+	j.Set("createdAt", sub.CreatedAt.Unix())
+
+	lobby := &Lobby{}
+	if err := db.DB.First(lobby, sub.LobbyID).Error; err == nil {
+		format := lobby.Type.Format()
+		team, class := format.SlotInfo(sub.Slot)
+		j.Set("type", format.Name)
+		j.Set("league", lobby.League)
+		j.Set("map", lobby.MapName)
+		j.Set("team", team)
+		j.Set("class", class)
+	}
+	return j
+}
+
+func DecorateSubListJSON(subs []*Substitute) *simplejson.Json {
+	var list []*simplejson.Json
+	for _, sub := range subs {
+		list = append(list, DecorateSubstituteJSON(sub))
+	}
+
+	j := simplejson.New()
+	j.Set("subs", list)
+	return j
+}
+
+// Sends the open subs to everyone
+func BroadcastSubList() {
+	subs, err := GetOpenSubstitutes()
+	if err != nil {
+		helpers.Logger.Warning("Failed to send sub list: %s", err.Error())
+		return
+	}
+
+	bytes, _ := DecorateSubListJSON(subs).Encode()
+	broadcaster.SendMessageToRoom(fmt.Sprintf("%s_public", config.Constants.GlobalChatRoom),
+		"subListData", string(bytes))
+}
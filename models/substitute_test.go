@@ -0,0 +1,62 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package models_test
+
+import (
+	"testing"
+
+	"github.com/TF2Stadium/Helen/models"
+	"github.com/TF2Stadium/Helen/testhelpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubstitute(t *testing.T) {
+	testhelpers.CleanupDB()
+
+	lobby := models.NewLobby("cp_badlands", models.LobbyTypeSixes, "ugc", models.ServerRecord{}, 0, false)
+	lobby.Save()
+	leaver := testhelpers.CreatePlayer()
+	lobby.AddPlayer(leaver, 4)
+
+	for _, state := range []models.LobbyState{models.LobbyStateWaiting,
+		models.LobbyStateReadyingUp, models.LobbyStateInProgress} {
+		lobby.SetState(state, models.TriggerDebug)
+	}
+	lobby.Save()
+
+	sub, tperr := models.NewSubstitute(lobby, leaver, models.SubReasonReported)
+	assert.Nil(t, tperr)
+	assert.Equal(t, 4, sub.Slot)
+	_, err := lobby.GetPlayerSlot(leaver)
+	assert.NotNil(t, err)
+
+	subs, err := models.GetOpenSubstitutes()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(subs))
+
+	j := models.DecorateSubstituteJSON(subs[0])
+	assert.Equal(t, "red", j.Get("team").MustString())
+	assert.Equal(t, "demoman", j.Get("class").MustString())
+
+	// the leaver can't take their own slot back
+	assert.NotNil(t, sub.Fill(leaver))
+
+	player := testhelpers.CreatePlayer()
+	assert.Nil(t, sub.Fill(player))
+	slot, err := lobby.GetPlayerSlot(player)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, slot)
+
+	other := testhelpers.CreatePlayer()
+	assert.NotNil(t, sub.Fill(other))
+
+	subs, _ = models.GetOpenSubstitutes()
+	assert.Equal(t, 0, len(subs))
+
+	history, err := leaver.GetSubstitutions()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(history))
+	assert.Equal(t, models.SubReasonReported, history[0].Reason)
+}
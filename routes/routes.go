@@ -18,6 +18,11 @@ func SetupHTTPRoutes() {
 	http.HandleFunc("/openidcallback", controllers.LoginCallbackHandler)
 	http.HandleFunc("/startLogin", controllers.LoginHandler)
 	http.HandleFunc("/logout", controllers.LogoutHandler)
+	http.HandleFunc("/admin/bans", controllers.AdminGetBansHandler)
+	http.HandleFunc("/admin/bans/ban", controllers.AdminBanHandler)
+	http.HandleFunc("/admin/bans/unban", controllers.AdminUnbanHandler)
+	http.HandleFunc("/admin/log", controllers.AdminLogExportHandler)
+	http.HandleFunc("/admin/stats/broadcaster", controllers.AdminBroadcasterStatsHandler)
 	if config.Constants.MockupAuth {
 		http.HandleFunc("/startMockLogin/", controllers.MockLoginHandler)
 	}
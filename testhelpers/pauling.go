@@ -0,0 +1,262 @@
+// Copyright (C) 2015  TF2Stadium
+// Use of this source code is governed by the GPLv3
+// that can be found in the COPYING file.
+
+package testhelpers
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/rpc"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TF2Stadium/Helen/config"
+	"github.com/TF2Stadium/Helen/models"
+)
+
+// A call Helen made to the fake Pauling
+type PaulingCall struct {
+	Method string
+	Args   interface{} // *models.Args, *models.ServerRecord or *models.ServerBootstrap
+}
+
+// An in-process Pauling serving the same net/rpc service as the real one.
+// Tests can make its methods fail and push events to Helen through it.
+type FakePauling struct {
+	lock     sync.Mutex
+	calls    []PaulingCall
+	failures map[string]string
+
+	events   chan models.Event
+	polled   chan bool
+	closed   chan bool
+	listener net.Listener
+}
+
+// Starts a fake Pauling on a free port and connects Helen to it. Call it
+// after CleanupDB, which resets the Pauling settings in config.
+func StartFakePauling() (*FakePauling, error) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, err
+	}
+
+	fake := &FakePauling{
+		failures: make(map[string]string),
+		events:   make(chan models.Event),
+		polled:   make(chan bool, 1),
+		closed:   make(chan bool),
+		listener: listener,
+	}
+
+	server := rpc.NewServer()
+	server.RegisterName("Pauling", &paulingService{fake})
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, server)
+	go http.Serve(listener, mux)
+
+	config.Constants.ServerMockUp = false
+	config.Constants.PaulingPort = strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+	models.PaulingConnect()
+
+	for i := 0; i < 50; i++ {
+		if models.GetPaulingStatus().State == models.PaulingConnected {
+			return fake, nil
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	fake.Close()
+	return nil, errors.New("Helen didn't connect to the fake Pauling")
+}
+
+// Stops the fake and turns the Pauling mock up back on
+func (fake *FakePauling) Close() {
+	fake.lock.Lock()
+	select {
+	case <-fake.closed:
+	default:
+		close(fake.closed)
+		fake.listener.Close()
+	}
+	fake.lock.Unlock()
+	config.Constants.ServerMockUp = true
+}
+
+// Makes every following call to method fail with the given message
+func (fake *FakePauling) Fail(method string, message string) {
+	fake.lock.Lock()
+	fake.failures[method] = message
+	fake.lock.Unlock()
+}
+
+// Makes calls to method succeed again
+func (fake *FakePauling) Succeed(method string) {
+	fake.lock.Lock()
+	delete(fake.failures, method)
+	fake.lock.Unlock()
+}
+
+// All calls made so far, in order
+func (fake *FakePauling) Calls() []PaulingCall {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	calls := make([]PaulingCall, len(fake.calls))
+	copy(calls, fake.calls)
+	return calls
+}
+
+// The arguments of every call made to method so far
+func (fake *FakePauling) CallsTo(method string) []interface{} {
+	var args []interface{}
+	for _, call := range fake.Calls() {
+		if call.Method == method {
+			args = append(args, call.Args)
+		}
+	}
+	return args
+}
+
+func (fake *FakePauling) ResetCalls() {
+	fake.lock.Lock()
+	fake.calls = nil
+	fake.lock.Unlock()
+}
+
+// Sends an event to Helen. The listener handles events one at a time, so
+// this returns once it has come back for the next one, i.e. after the
+// event was handled.
+func (fake *FakePauling) SendEvent(event models.Event) error {
+	select {
+	case <-fake.polled:
+	default:
+	}
+
+	timeout := time.After(time.Second * 5)
+	select {
+	case fake.events <- event:
+	case <-timeout:
+		return errors.New("nobody is listening for events")
+	}
+
+	select {
+	case <-fake.polled:
+		return nil
+	case <-timeout:
+		return errors.New("event wasn't handled in time")
+	}
+}
+
+func (fake *FakePauling) PlayerConn(lobbyid uint, steamid string) error {
+	return fake.SendEvent(models.Event{"name": "playerConn", "lobbyId": lobbyid, "steamId": steamid})
+}
+
+func (fake *FakePauling) PlayerDisc(lobbyid uint, steamid string) error {
+	return fake.SendEvent(models.Event{"name": "playerDisc", "lobbyId": lobbyid, "steamId": steamid})
+}
+
+func (fake *FakePauling) PlayerRep(lobbyid uint, steamid string) error {
+	return fake.SendEvent(models.Event{"name": "playerRep", "lobbyId": lobbyid, "steamId": steamid})
+}
+
+func (fake *FakePauling) MatchEnded(lobbyid uint) error {
+	return fake.SendEvent(models.Event{"name": "matchEnded", "lobbyId": lobbyid})
+}
+
+func (fake *FakePauling) GetServers() error {
+	return fake.SendEvent(models.Event{"name": "getServers"})
+}
+
+func (fake *FakePauling) call(method string, args interface{}) error {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.calls = append(fake.calls, PaulingCall{method, args})
+	if message, ok := fake.failures[method]; ok {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// The error a call to method returns, without recording the call
+func (fake *FakePauling) failure(method string) error {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	if message, ok := fake.failures[method]; ok {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// The RPC service itself. It's kept apart from FakePauling since net/rpc
+// complains about exported methods that don't look like RPC methods.
+type paulingService struct {
+	fake *FakePauling
+}
+
+func (s *paulingService) SetupServer(args *models.Args, reply *models.Args) error {
+	return s.fake.call("SetupServer", args)
+}
+
+func (s *paulingService) VerifyInfo(info *models.ServerRecord, reply *models.Args) error {
+	return s.fake.call("VerifyInfo", info)
+}
+
+func (s *paulingService) AllowPlayer(args *models.Args, reply *models.Args) error {
+	return s.fake.call("AllowPlayer", args)
+}
+
+func (s *paulingService) DisallowPlayer(args *models.Args, reply *models.Args) error {
+	return s.fake.call("DisallowPlayer", args)
+}
+
+func (s *paulingService) End(args *models.Args, reply *models.Args) error {
+	return s.fake.call("End", args)
+}
+
+func (s *paulingService) SetupVerifier(info *models.ServerBootstrap, reply *struct{}) error {
+	return s.fake.call("SetupVerifier", info)
+}
+
+func (s *paulingService) WaitEvent(args *models.Args, reply *models.Event) error {
+	// Fail("WaitEvent", "rpc: can't find method Pauling.WaitEvent") acts
+	// like a Pauling that only has GetEvent
+	if err := s.fake.failure("WaitEvent"); err != nil {
+		return err
+	}
+
+	select {
+	case s.fake.polled <- true:
+	default:
+	}
+
+	// blocks like the real one, an empty event straight away would have
+	// Helen spinning on WaitEvent until it notices the fake is gone
+	select {
+	case event := <-s.fake.events:
+		*reply = event
+		return nil
+	case <-s.fake.closed:
+		return rpc.ErrShutdown
+	}
+}
+
+// What Paulings without WaitEvent have
+func (s *paulingService) GetEvent(args *models.Args, reply *models.Event) error {
+	select {
+	case s.fake.polled <- true:
+	default:
+	}
+
+	select {
+	case event := <-s.fake.events:
+		*reply = event
+		return s.fake.call("GetEvent", args)
+	default:
+		*reply = models.Event{"empty": true}
+	}
+	return nil
+}